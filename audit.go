@@ -0,0 +1,189 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Audit events
+const (
+	AuditRequest  = "request"
+	AuditResponse = "response"
+)
+
+// RedactedValue replaces redacted payload values
+const RedactedValue = "[REDACTED]"
+
+// AuditRecord tells who called which action on which service and how it went
+type AuditRecord struct {
+	Time              time.Time   `json:"time"`
+	Event             string      `json:"event"` // AuditRequest when a request was handled, AuditResponse when answered
+	DataTransactionID string      `json:"dataTransactionId"`
+	ActionID          string      `json:"actionId"`
+	Service           string      `json:"service"` // Called service
+	Action            string      `json:"action"`
+	Caller            string      `json:"caller"` // Calling service
+	TenantID          string      `json:"tenantId,omitempty"`
+	UserID            string      `json:"userId,omitempty"`
+	Success           bool        `json:"success"`
+	ErrorCode         string      `json:"errorCode,omitempty"`
+	Payload           interface{} `json:"payload,omitempty"` // Redacted payload
+
+	PrevHash string `json:"prevHash,omitempty"` // Hash of the previous record, set by chaining sinks
+	Hash     string `json:"hash,omitempty"`     // Hash of this record and PrevHash, set by chaining sinks
+}
+
+// AuditSink stores audit records
+type AuditSink interface {
+	Write(record *AuditRecord) error
+}
+
+// AuditRedaction configures what audit records keep of the payloads
+// Payloads are also redacted by the rules of RedactPaths and RedactType
+type AuditRedaction struct {
+	OmitPayload bool     // Drop the payloads
+	Fields      []string // Keys masked at any depth (password) or dotted paths from the root (card.number)
+}
+
+type auditor struct {
+	sink      AuditSink
+	redaction AuditRedaction
+	fields    []string
+}
+
+// SetAudit sets the sink receiving an audit record for every request handled and every response sent
+func (gom *Gommunicator) SetAudit(sink AuditSink, redaction AuditRedaction) *Gommunicator {
+	if sink == nil {
+		gom.auditor = nil
+		return gom
+	}
+
+	fields := make([]string, len(redaction.Fields))
+	for i, field := range redaction.Fields {
+		fields[i] = normalizeRule(field)
+	}
+
+	gom.auditor = &auditor{sink: sink, redaction: redaction, fields: fields}
+	return gom
+}
+
+// genericPayload turns Data into JSON like values, false when it can't be read
+func genericPayload(contentType string, data interface{}) (interface{}, bool) {
+	if data == nil {
+		return nil, true
+	}
+
+	if !isJSON(contentType) {
+		var decoded interface{}
+		if err := decodeData(contentType, data, &decoded, false); err != nil {
+			return nil, false
+		}
+		data = stringKeys(decoded)
+	}
+
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, false
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return nil, false
+	}
+
+	return generic, true
+}
+
+// stringKeys turns the map[interface{}]interface{} some codecs decode into JSON marshalable maps
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = stringKeys(item)
+		}
+		return converted
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = stringKeys(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = stringKeys(item)
+		}
+	}
+
+	return value
+}
+
+// auditPayload returns the payload of an action as recorded, see Gommunicator.redact
+func (gom *Gommunicator) auditPayload(action, contentType string, data interface{}) interface{} {
+	if gom.auditor.redaction.OmitPayload {
+		return nil
+	}
+
+	return gom.redact(action, contentType, data, gom.auditor.fields...)
+}
+
+func (gom *Gommunicator) writeAudit(record *AuditRecord) {
+	if err := gom.auditor.sink.Write(record); err != nil {
+		gom.onErr(err)
+	}
+}
+
+// auditRequest records a handled request, err is the handling error or the error it was answered with
+func (gom *Gommunicator) auditRequest(request *DataTransactionRequest, err error) {
+	if gom.auditor == nil {
+		return
+	}
+
+	record := &AuditRecord{
+		Time:              time.Now().UTC(),
+		Event:             AuditRequest,
+		DataTransactionID: request.ID,
+		ActionID:          actionIDValue(request.ActionID),
+		Service:           request.Service,
+		Action:            request.Action,
+		Caller:            request.IncomingService,
+		TenantID:          request.Headers[HeaderTenantID],
+		UserID:            request.Headers[HeaderUserID],
+		Success:           err == nil,
+		Payload:           gom.auditPayload(request.Action, request.ContentType, request.Data),
+	}
+
+	var mapErr MapErr
+	if errors.As(err, &mapErr) {
+		record.ErrorCode = mapErr.GetCode()
+	}
+
+	gom.writeAudit(record)
+}
+
+// auditResponse records a response sent to the caller of request
+func (gom *Gommunicator) auditResponse(request *DataTransactionRequest, response *DataTransactionResponse) {
+	if gom.auditor == nil {
+		return
+	}
+
+	record := &AuditRecord{
+		Time:              time.Now().UTC(),
+		Event:             AuditResponse,
+		DataTransactionID: response.ID,
+		ActionID:          actionIDValue(response.ActionID),
+		Service:           request.Service,
+		Action:            request.Action,
+		Caller:            request.IncomingService,
+		TenantID:          response.Headers[HeaderTenantID],
+		UserID:            response.Headers[HeaderUserID],
+		Success:           response.Success,
+		Payload:           gom.auditPayload(request.Action, response.ContentType, response.Data),
+	}
+
+	if response.Error != nil {
+		record.ErrorCode = response.Error.Code
+	}
+
+	gom.writeAudit(record)
+}
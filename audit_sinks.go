@@ -0,0 +1,311 @@
+package gommunicator
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAuditChainBroken is returned when a hash chained audit log was modified
+var ErrAuditChainBroken = errors.New("audit chain broken")
+
+// chainHash returns the hash of a record chained to the previous hash
+func chainHash(record *AuditRecord) (string, error) {
+	unhashed := *record
+	unhashed.Hash = ""
+
+	raw, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(append([]byte(record.PrevHash), raw...))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// FileAuditSink appends audit records as JSON lines
+// Every record carries the hash of the previous one, so removing or editing a line breaks the chain, see VerifyAuditFile
+type FileAuditSink struct {
+	lock     sync.Mutex
+	file     *os.File
+	lastHash string
+}
+
+// readAuditFile calls read for every record of an audit file
+func readAuditFile(path string, read func(line int, record *AuditRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		record := new(AuditRecord)
+		if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+			return fmt.Errorf("audit line %d: %w", line, err)
+		}
+
+		if err := read(line, record); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+// NewFileAuditSink opens, or creates, the audit file at path continuing its chain
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	sink := new(FileAuditSink)
+
+	err := readAuditFile(path, func(line int, record *AuditRecord) error {
+		sink.lastHash = record.Hash
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	sink.file, err = os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return sink, nil
+}
+
+// Write appends a record to the chain
+func (sink *FileAuditSink) Write(record *AuditRecord) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	chained := *record
+	chained.PrevHash = sink.lastHash
+
+	hash, err := chainHash(&chained)
+	if err != nil {
+		return err
+	}
+	chained.Hash = hash
+
+	line, err := json.Marshal(&chained)
+	if err != nil {
+		return err
+	}
+
+	if _, err := sink.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	sink.lastHash = hash
+	return nil
+}
+
+// Close closes the audit file
+func (sink *FileAuditSink) Close() error {
+	return sink.file.Close()
+}
+
+// VerifyAuditFile checks the hash chain of an audit file, ErrAuditChainBroken locates the first altered line
+func VerifyAuditFile(path string) error {
+	previous := ""
+
+	return readAuditFile(path, func(line int, record *AuditRecord) error {
+		hash, err := chainHash(record)
+		if err != nil {
+			return err
+		}
+
+		if record.PrevHash != previous || record.Hash != hash {
+			return fmt.Errorf("%w at line %d", ErrAuditChainBroken, line)
+		}
+
+		previous = record.Hash
+		return nil
+	})
+}
+
+// SQLAuditSink inserts audit records into a table
+// Records are hash chained like the FileAuditSink ones, seq orders the chain and, being the primary key,
+// keeps concurrent writers from forking it, see Verify
+// Placeholder formats the n-th (1 based) query parameter, ? by default, use DollarPlaceholder for PostgreSQL
+//
+//	CREATE TABLE audit (
+//		seq BIGINT PRIMARY KEY, time TIMESTAMP, event VARCHAR(16), data_transaction_id VARCHAR(64),
+//		action_id VARCHAR(64), service VARCHAR(255), action VARCHAR(255), caller VARCHAR(255),
+//		tenant_id VARCHAR(255), user_id VARCHAR(255), success BOOLEAN, error_code VARCHAR(255),
+//		payload TEXT, prev_hash VARCHAR(64), hash VARCHAR(64)
+//	)
+type SQLAuditSink struct {
+	DB          *sql.DB
+	Table       string
+	Placeholder func(n int) string
+
+	lock     sync.Mutex
+	loaded   bool
+	lastSeq  int64
+	lastHash string
+}
+
+// DollarPlaceholder formats PostgreSQL query parameters
+func DollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// NewSQLAuditSink returns a new SQLAuditSink inserting into table
+func NewSQLAuditSink(db *sql.DB, table string) *SQLAuditSink {
+	return &SQLAuditSink{DB: db, Table: table}
+}
+
+var auditColumns = []string{
+	"seq", "time", "event", "data_transaction_id", "action_id", "service", "action",
+	"caller", "tenant_id", "user_id", "success", "error_code", "payload", "prev_hash", "hash",
+}
+
+// sqlAuditWriteAttempts is how many times a write is retried when another writer appended to the chain meanwhile
+const sqlAuditWriteAttempts = 3
+
+// storedRecord returns the record as it reads back from the table, which is what gets hashed
+// Times keep microseconds, the precision of most databases, and payloads are the stored JSON
+func storedRecord(record *AuditRecord, payload sql.NullString) *AuditRecord {
+	stored := *record
+	stored.Time = record.Time.UTC().Truncate(time.Microsecond)
+	stored.Payload = nil
+	if payload.Valid {
+		stored.Payload = json.RawMessage(payload.String)
+	}
+	return &stored
+}
+
+// loadTail reads the end of the chain
+func (sink *SQLAuditSink) loadTail() error {
+	row := sink.DB.QueryRow(fmt.Sprintf("SELECT seq, hash FROM %s ORDER BY seq DESC LIMIT 1", sink.Table))
+
+	sink.lastSeq, sink.lastHash = 0, ""
+	if err := row.Scan(&sink.lastSeq, &sink.lastHash); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	sink.loaded = true
+	return nil
+}
+
+// Write appends a record to the chain
+func (sink *SQLAuditSink) Write(record *AuditRecord) error {
+	placeholders := make([]string, len(auditColumns))
+	for i := range placeholders {
+		if sink.Placeholder == nil {
+			placeholders[i] = "?"
+		} else {
+			placeholders[i] = sink.Placeholder(i + 1)
+		}
+	}
+
+	var payload sql.NullString
+	if record.Payload != nil {
+		raw, err := json.Marshal(record.Payload)
+		if err != nil {
+			return err
+		}
+		payload = sql.NullString{String: string(raw), Valid: true}
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)",
+		sink.Table, strings.Join(auditColumns, ", "), strings.Join(placeholders, ", "),
+	)
+
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+
+	var err error
+	for attempt := 0; attempt < sqlAuditWriteAttempts; attempt++ {
+		if !sink.loaded {
+			if err = sink.loadTail(); err != nil {
+				return err
+			}
+		}
+
+		chained := storedRecord(record, payload)
+		chained.PrevHash = sink.lastHash
+
+		var hash string
+		if hash, err = chainHash(chained); err != nil {
+			return err
+		}
+
+		_, err = sink.DB.Exec(
+			query,
+			sink.lastSeq+1, chained.Time, record.Event, record.DataTransactionID, record.ActionID, record.Service,
+			record.Action, record.Caller, record.TenantID, record.UserID, record.Success, record.ErrorCode, payload,
+			chained.PrevHash, hash,
+		)
+		if err == nil {
+			sink.lastSeq++
+			sink.lastHash = hash
+			return nil
+		}
+
+		// Another writer may have taken the sequence number, reload the end of the chain
+		sink.loaded = false
+	}
+
+	return err
+}
+
+// Verify checks the hash chain of the table, ErrAuditChainBroken locates the first altered record
+func (sink *SQLAuditSink) Verify() error {
+	rows, err := sink.DB.Query(fmt.Sprintf(
+		"SELECT %s FROM %s ORDER BY seq", strings.Join(auditColumns, ", "), sink.Table,
+	))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	previous := ""
+	for rows.Next() {
+		var seq int64
+		var payload sql.NullString
+		record := new(AuditRecord)
+
+		err := rows.Scan(
+			&seq, &record.Time, &record.Event, &record.DataTransactionID, &record.ActionID, &record.Service,
+			&record.Action, &record.Caller, &record.TenantID, &record.UserID, &record.Success, &record.ErrorCode,
+			&payload, &record.PrevHash, &record.Hash,
+		)
+		if err != nil {
+			return err
+		}
+
+		stored := storedRecord(record, payload)
+		hash, err := chainHash(stored)
+		if err != nil {
+			return err
+		}
+
+		if stored.PrevHash != previous || stored.Hash != hash {
+			return fmt.Errorf("%w at seq %d", ErrAuditChainBroken, seq)
+		}
+
+		previous = stored.Hash
+	}
+
+	return rows.Err()
+}
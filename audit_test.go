@@ -0,0 +1,103 @@
+package gommunicator
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileAuditSinkChain(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	sink, err := NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditSink failed: %s", err.Error())
+	}
+
+	gom := NewGommunicator(nil, nil, nil, "", "users", "", "").SetLogState(false)
+	gom.SetAudit(sink, AuditRedaction{Fields: []string{"password", "card.number"}})
+	payload := gom.auditPayload("users.create", "", map[string]interface{}{
+		"user":     "ana",
+		"password": "secret",
+		"card":     map[string]interface{}{"number": "4111", "brand": "visa"},
+	})
+
+	for _, action := range []string{"users.create", "cards.add"} {
+		if err := sink.Write(&AuditRecord{Time: time.Now().UTC(), Action: action, Success: true, Payload: payload}); err != nil {
+			t.Fatalf("Write failed: %s", err.Error())
+		}
+	}
+	sink.Close()
+
+	// Reopening continues the chain
+	sink, err = NewFileAuditSink(path)
+	if err != nil {
+		t.Fatalf("NewFileAuditSink failed: %s", err.Error())
+	}
+	sink.Write(&AuditRecord{Time: time.Now().UTC(), Action: "users.delete"})
+	sink.Close()
+
+	if err := VerifyAuditFile(path); err != nil {
+		t.Fatalf("expected a valid chain, got %s", err.Error())
+	}
+
+	content, _ := os.ReadFile(path)
+	if strings.Contains(string(content), "secret") || strings.Contains(string(content), "4111") || !strings.Contains(string(content), "visa") {
+		t.Fatalf("unexpected redaction: %s", content)
+	}
+
+	tampered := strings.Replace(string(content), "users.create", "users.update", 1)
+	os.WriteFile(path, []byte(tampered), 0600)
+
+	if err := VerifyAuditFile(path); !errors.Is(err, ErrAuditChainBroken) {
+		t.Fatalf("expected the chain to be broken, got %v", err)
+	}
+}
+
+func TestSQLAuditSinkChain(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %s", err.Error())
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`CREATE TABLE audit (
+		seq BIGINT PRIMARY KEY, time TIMESTAMP, event VARCHAR(16), data_transaction_id VARCHAR(64),
+		action_id VARCHAR(64), service VARCHAR(255), action VARCHAR(255), caller VARCHAR(255),
+		tenant_id VARCHAR(255), user_id VARCHAR(255), success BOOLEAN, error_code VARCHAR(255),
+		payload TEXT, prev_hash VARCHAR(64), hash VARCHAR(64)
+	)`)
+	if err != nil {
+		t.Fatalf("CREATE TABLE failed: %s", err.Error())
+	}
+
+	// Two sinks on the same table, like two instances of a service, share one chain
+	first, second := NewSQLAuditSink(db, "audit"), NewSQLAuditSink(db, "audit")
+	writes := []struct {
+		sink   *SQLAuditSink
+		action string
+	}{{first, "users.create"}, {second, "cards.add"}, {first, "users.delete"}}
+
+	for _, write := range writes {
+		record := &AuditRecord{Time: time.Now(), Action: write.action, Success: true, Payload: map[string]interface{}{"user": "ana", "age": 30}}
+		if err := write.sink.Write(record); err != nil {
+			t.Fatalf("Write failed: %s", err.Error())
+		}
+	}
+
+	if err := first.Verify(); err != nil {
+		t.Fatalf("expected a valid chain, got %s", err.Error())
+	}
+
+	if _, err := db.Exec("UPDATE audit SET caller = 'intruder' WHERE seq = 2"); err != nil {
+		t.Fatalf("UPDATE failed: %s", err.Error())
+	}
+
+	if err := first.Verify(); !errors.Is(err, ErrAuditChainBroken) || !strings.Contains(err.Error(), "seq 2") {
+		t.Fatalf("expected the chain to be broken at seq 2, got %v", err)
+	}
+}
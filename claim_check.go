@@ -0,0 +1,373 @@
+package gommunicator
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
+)
+
+// DefaultClaimCheckThreshold is the message size, in bytes, above which payloads are offloaded
+// The size is the one sent to SNS, once compressed, encrypted and base64 encoded,
+// it leaves room for the message attributes below the 256KB SNS/SQS limit
+const DefaultClaimCheckThreshold = 200 * 1024
+
+// ClaimCheck is the reference to a payload offloaded to a BlobStore
+// Payloads of an encrypting Gommunicator are stored encrypted, KeyID and DataKey unwrap them
+type ClaimCheck struct {
+	Key     string `json:"key"`
+	Size    int    `json:"size"`
+	KeyID   string `json:"keyId,omitempty"`
+	DataKey string `json:"dataKey,omitempty"`
+}
+
+// BlobStore stores offloaded payloads
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	// DeleteOlderThan removes every blob stored before t, returning how many were removed
+	DeleteOlderThan(t time.Time) (int, error)
+}
+
+// S3BlobStore is a BlobStore backed by a S3 bucket
+type S3BlobStore struct {
+	client *s3.S3
+	bucket string
+	prefix string
+}
+
+// NewS3BlobStore returns a new S3BlobStore storing blobs under prefix
+func NewS3BlobStore(client *s3.S3, bucket, prefix string) *S3BlobStore {
+	return &S3BlobStore{
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+// Put stores a blob
+func (store *S3BlobStore) Put(key string, data []byte) error {
+	_, err := store.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(store.prefix + key),
+		Body:   bytes.NewReader(data),
+	})
+
+	return err
+}
+
+// Get reads a blob
+func (store *S3BlobStore) Get(key string) ([]byte, error) {
+	output, err := store.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(store.prefix + key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+
+	return ioutil.ReadAll(output.Body)
+}
+
+// Delete removes a blob
+func (store *S3BlobStore) Delete(key string) error {
+	_, err := store.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(store.bucket),
+		Key:    aws.String(store.prefix + key),
+	})
+
+	return err
+}
+
+// DeleteOlderThan removes every blob stored before t
+func (store *S3BlobStore) DeleteOlderThan(t time.Time) (int, error) {
+	expired := make([]*s3.ObjectIdentifier, 0)
+
+	err := store.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(store.bucket),
+		Prefix: aws.String(store.prefix),
+	}, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+		for _, object := range page.Contents {
+			if object.LastModified != nil && object.LastModified.Before(t) {
+				expired = append(expired, &s3.ObjectIdentifier{Key: object.Key})
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// DeleteObjects accepts at most 1000 keys per call
+	deleted := 0
+	for start := 0; start < len(expired); start += 1000 {
+		end := start + 1000
+		if end > len(expired) {
+			end = len(expired)
+		}
+
+		_, err := store.client.DeleteObjects(&s3.DeleteObjectsInput{
+			Bucket: aws.String(store.bucket),
+			Delete: &s3.Delete{
+				Objects: expired[start:end],
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted += end - start
+	}
+
+	return deleted, nil
+}
+
+// LocalBlobStore is a BlobStore backed by a local directory, useful for development and tests
+type LocalBlobStore struct {
+	dir string
+}
+
+// NewLocalBlobStore returns a new LocalBlobStore, creating the directory if needed
+func NewLocalBlobStore(dir string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return &LocalBlobStore{dir: dir}, nil
+}
+
+func (store *LocalBlobStore) path(key string) (string, error) {
+	path := filepath.Join(store.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(store.dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid blob key %s", key)
+	}
+
+	return path, nil
+}
+
+// Put stores a blob
+func (store *LocalBlobStore) Put(key string, data []byte) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// Get reads a blob
+func (store *LocalBlobStore) Get(key string) ([]byte, error) {
+	path, err := store.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+// Delete removes a blob
+func (store *LocalBlobStore) Delete(key string) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+// DeleteOlderThan removes every blob stored before t
+func (store *LocalBlobStore) DeleteOlderThan(t time.Time) (int, error) {
+	deleted := 0
+
+	err := filepath.Walk(store.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() || !info.ModTime().Before(t) {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+
+		deleted++
+		return nil
+	})
+
+	return deleted, err
+}
+
+// SetClaimCheck enables offloading of Data to the store when the message sent to SNS is bigger than threshold bytes
+// Receivers must set the same store to resolve references, blobs are removed after the retention
+// A threshold lower or equal to zero means DefaultClaimCheckThreshold, a zero retention keeps blobs forever
+func (gom *Gommunicator) SetClaimCheck(store BlobStore, threshold int, retention time.Duration) *Gommunicator {
+	if threshold <= 0 {
+		threshold = DefaultClaimCheckThreshold
+	}
+
+	gom.blobStore = store
+	gom.claimCheckThreshold = threshold
+	gom.claimCheckRetention = retention
+	return gom
+}
+
+// messageSize returns the size of the SNS message encodeBody makes of an envelope
+// Encryption is accounted for without being done, as a new data key may be needed for it
+func (gom *Gommunicator) messageSize(envelope []byte) (int, error) {
+	size, binary := len(envelope), false
+
+	if gom.compressor != nil && size > gom.compressionThreshold {
+		compressed, err := gom.compressor.Compress(envelope)
+		if err != nil {
+			return 0, err
+		}
+
+		if base64.StdEncoding.EncodedLen(len(compressed)) < size {
+			size, binary = len(compressed), true
+		}
+	}
+
+	if gom.encrypter != nil {
+		size, binary = size+sealOverhead, true
+	}
+
+	if binary {
+		size = base64.StdEncoding.EncodedLen(size)
+	}
+
+	return size, nil
+}
+
+// checkIn serializes an envelope, offloading its data to the blob store when the envelope is too big to travel
+// marshal serializes the envelope with the data and claim check it is given
+func (gom *Gommunicator) checkIn(dtID string, data interface{}, marshal func(data interface{}, claim *ClaimCheck) ([]byte, error)) ([]byte, error) {
+	envelope, err := marshal(data, nil)
+	if err != nil || gom.blobStore == nil || data == nil {
+		return envelope, err
+	}
+
+	size, err := gom.messageSize(envelope)
+	if err != nil || size <= gom.claimCheckThreshold {
+		return envelope, err
+	}
+
+	raw, ok := data.([]byte)
+	if !ok {
+		raw, err = json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if dtID == "" {
+		dtID = "no-transaction"
+	}
+
+	claim := &ClaimCheck{Key: fmt.Sprintf("%s/%s", dtID, uuid.New().String()), Size: len(raw)}
+
+	blob := raw
+	if gom.encrypter != nil {
+		blob, claim.KeyID, claim.DataKey, err = gom.encrypter.seal(raw)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := gom.blobStore.Put(claim.Key, blob); err != nil {
+		return nil, err
+	}
+
+	gom.tryLogInfo("Payload offloaded to the blob store", F(FieldDataTransactionID, dtID), F("bytes", len(raw)), F("message_bytes", size), F("key", claim.Key))
+
+	return marshal(nil, claim)
+}
+
+// checkOut resolves a claim check reference back to the data it refers to
+func (gom *Gommunicator) checkOut(claim *ClaimCheck, contentType string) (interface{}, error) {
+	if gom.blobStore == nil {
+		return nil, errors.New("received a claim check but no blob store is configured")
+	}
+
+	raw, err := gom.blobStore.Get(claim.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	if claim.KeyID != "" {
+		if gom.encrypter == nil {
+			return nil, errors.New("received an encrypted claim check but no key provider is configured")
+		}
+
+		if raw, err = gom.encrypter.decrypt(raw, claim.KeyID, claim.DataKey); err != nil {
+			return nil, err
+		}
+	}
+
+	if !isJSON(contentType) {
+		return raw, nil
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (gom *Gommunicator) sweepBlobs() {
+	interval := gom.claimCheckRetention / 2
+	if interval > time.Hour {
+		interval = time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gom.stop:
+			return
+		case <-ticker.C:
+			deleted, err := gom.blobStore.DeleteOlderThan(time.Now().Add(-gom.claimCheckRetention))
+			if err != nil {
+				gom.onErr(err)
+				continue
+			}
+
+			if deleted > 0 {
+				gom.tryLogInfo("Expired payloads removed from the blob store", F(FieldService, gom.ServiceName), F("deleted", deleted))
+			}
+		}
+	}
+}
+
+func (gom *Gommunicator) startBlobSweeper() {
+	if gom.blobStore != nil && gom.claimCheckRetention > 0 {
+		go gom.sweepBlobs()
+	}
+}
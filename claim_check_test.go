@@ -0,0 +1,194 @@
+package gommunicator
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+func claimCheckRequest(t *testing.T, gom *Gommunicator, payload interface{}) (*DataTransactionRequest, []byte) {
+	request, envelope, err := gom.newRequest(&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve", Payload: payload})
+	if err != nil {
+		t.Fatalf("newRequest failed: %s", err.Error())
+	}
+
+	return request, envelope
+}
+
+func TestClaimCheckThreshold(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore failed: %s", err.Error())
+	}
+
+	payload := map[string]string{"sku": strings.Repeat("a", 1024)}
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false)
+	_, envelope := claimCheckRequest(t, gom, payload)
+
+	// The envelope size decides, not the payload size
+	gom.SetClaimCheck(store, len(envelope), 0)
+	if request, _ := claimCheckRequest(t, gom, payload); request.ClaimCheck != nil {
+		t.Fatalf("expected an envelope of exactly the threshold to be kept")
+	}
+
+	gom.SetClaimCheck(store, len(envelope)-1, 0)
+	request, offloaded := claimCheckRequest(t, gom, payload)
+	if request.ClaimCheck == nil || request.Data != nil {
+		t.Fatalf("expected an envelope over the threshold to be offloaded")
+	}
+
+	if len(offloaded) >= len(envelope) {
+		t.Fatalf("expected the offloaded envelope to be smaller, got %d bytes", len(offloaded))
+	}
+
+	received := new(DataTransactionRequest)
+	if err := json.Unmarshal(offloaded, received); err != nil {
+		t.Fatalf("unmarshal failed: %s", err.Error())
+	}
+
+	data, err := gom.checkOut(received.ClaimCheck, received.ContentType)
+	if err != nil {
+		t.Fatalf("checkOut failed: %s", err.Error())
+	}
+
+	var decoded map[string]string
+	if err := decode(data, &decoded); err != nil || decoded["sku"] != payload["sku"] {
+		t.Fatalf("expected the payload back from the claim check, got %v", err)
+	}
+}
+
+func TestClaimCheckBinaryEnvelope(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore failed: %s", err.Error())
+	}
+
+	// Codec bytes travel base64 encoded, so the envelope is a third bigger than the payload
+	payload := bytes.Repeat([]byte{1}, 3000)
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false).SetClaimCheck(store, 3500, 0)
+
+	request, _, err := gom.newRequest(&ExecInput{Service: "stock", Action: "stock.reserve", Payload: payload, Codec: rawCodec{}})
+	if err != nil {
+		t.Fatalf("newRequest failed: %s", err.Error())
+	}
+
+	if request.ClaimCheck == nil {
+		t.Fatalf("expected the base64 encoded payload to be offloaded")
+	}
+
+	data, err := gom.checkOut(request.ClaimCheck, request.ContentType)
+	if err != nil || !bytes.Equal(data.([]byte), payload) {
+		t.Fatalf("expected the codec bytes back from the claim check, got %v", err)
+	}
+}
+
+func TestClaimCheckEncryption(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalBlobStore(dir)
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore failed: %s", err.Error())
+	}
+
+	provider, err := NewStaticKeyProvider("master", bytes.Repeat([]byte{7}, dataKeySize))
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider failed: %s", err.Error())
+	}
+
+	secret := strings.Repeat("secret", 100)
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false).SetClaimCheck(store, 100, 0).SetEncryption(provider, 0)
+
+	request, _ := claimCheckRequest(t, gom, map[string]string{"card": secret})
+	if request.ClaimCheck == nil || request.ClaimCheck.KeyID != "master" {
+		t.Fatalf("expected an encrypted claim check, got %+v", request.ClaimCheck)
+	}
+
+	blob, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(request.ClaimCheck.Key)))
+	if err != nil {
+		t.Fatalf("blob not stored: %s", err.Error())
+	}
+
+	if bytes.Contains(blob, []byte("secret")) {
+		t.Fatalf("expected the blob to be encrypted")
+	}
+
+	data, err := gom.checkOut(request.ClaimCheck, request.ContentType)
+	if err != nil {
+		t.Fatalf("checkOut failed: %s", err.Error())
+	}
+
+	var decoded map[string]string
+	if err := decode(data, &decoded); err != nil || decoded["card"] != secret {
+		t.Fatalf("expected the decrypted payload, got %v", err)
+	}
+
+	plain := NewGommunicator(nil, nil, nil, "", "stock", "", "").SetLogState(false).SetClaimCheck(store, 100, 0)
+	if _, err := plain.checkOut(request.ClaimCheck, request.ContentType); err == nil {
+		t.Fatalf("expected encrypted blobs to need a key provider")
+	}
+}
+
+// rawCodec passes byte slices through, standing for binary codecs
+type rawCodec struct{}
+
+func (rawCodec) ContentType() string {
+	return "application/octet-stream"
+}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error) {
+	return v.([]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v interface{}) error {
+	*(v.(*[]byte)) = data
+	return nil
+}
+
+func TestClaimCheckEncodedSize(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocalBlobStore failed: %s", err.Error())
+	}
+
+	provider, err := NewStaticKeyProvider("k1", bytes.Repeat([]byte{7}, dataKeySize))
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider failed: %s", err.Error())
+	}
+
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false).SetEncryption(provider, 0)
+	payload := map[string]string{"sku": strings.Repeat("a", 4096)}
+	_, envelope := claimCheckRequest(t, gom, payload)
+
+	// The envelope is under the threshold, the encrypted and base64 encoded message is not
+	threshold := len(envelope) + 100
+	gom.SetClaimCheck(store, threshold, 0)
+
+	request, offloaded := claimCheckRequest(t, gom, payload)
+	if request.ClaimCheck == nil {
+		t.Fatalf("expected the payload to be offloaded")
+	}
+
+	message, err := gom.encodeBody(offloaded, map[string]*sns.MessageAttributeValue{})
+	if err != nil {
+		t.Fatalf("encodeBody failed: %s", err.Error())
+	}
+
+	if len(message) > threshold {
+		t.Fatalf("expected the message sent under the threshold, got %d bytes", len(message))
+	}
+
+	// Sizes the messages exactly: a message of the threshold is kept
+	size, _ := gom.messageSize(envelope)
+	if message, _ := gom.encodeBody(envelope, map[string]*sns.MessageAttributeValue{}); len(message) != size {
+		t.Fatalf("expected a message of %d bytes, got %d", size, len(message))
+	}
+
+	gom.SetClaimCheck(store, size, 0)
+	if request, _ := claimCheckRequest(t, gom, payload); request.ClaimCheck != nil {
+		t.Fatalf("expected a message of exactly the threshold to be kept")
+	}
+}
@@ -0,0 +1,186 @@
+package gommunicator
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ContentTypeJSON is the content type of the default codec
+const ContentTypeJSON = "application/json"
+
+// Codec serializes the Data of requests and responses
+// The envelope itself is always JSON since SNS messages are strings,
+// binary codecs have their output base64 encoded inside the envelope
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// StrictCodec is a Codec able to reject fields unknown to the target, used by DecodeStrict
+type StrictCodec interface {
+	Codec
+	UnmarshalStrict(data []byte, v interface{}) error
+}
+
+// ErrStrictUnsupported is returned by DecodeStrict when the codec of the data is not a StrictCodec
+var ErrStrictUnsupported = errors.New("codec does not support strict decoding")
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// JSONCodec is the default codec, its data is embedded as is in the envelope
+var JSONCodec Codec = jsonCodec{}
+
+var codecs = map[string]Codec{ContentTypeJSON: JSONCodec}
+var codecsLock sync.RWMutex
+
+// RegisterCodec makes a codec available for decoding incoming messages
+func RegisterCodec(codec Codec) {
+	codecsLock.Lock()
+	defer codecsLock.Unlock()
+	codecs[codec.ContentType()] = codec
+}
+
+func codecFor(contentType string) (Codec, error) {
+	if isJSON(contentType) {
+		return JSONCodec, nil
+	}
+
+	codecsLock.RLock()
+	defer codecsLock.RUnlock()
+
+	if codec, ok := codecs[contentType]; ok == true {
+		return codec, nil
+	}
+
+	return nil, fmt.Errorf("no codec registered for content type %s", contentType)
+}
+
+func isJSON(contentType string) bool {
+	return contentType == "" || contentType == ContentTypeJSON
+}
+
+// encodeData encodes data with the codec, returning what goes on Data and the content type
+// JSON data is kept untouched so it stays readable by older consumers
+func encodeData(codec Codec, data interface{}) (interface{}, string, error) {
+	// Nil data travels as is, not every codec can marshal it
+	if codec == nil || isJSON(codec.ContentType()) || data == nil {
+		return data, "", nil
+	}
+
+	encoded, err := codec.Marshal(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return encoded, codec.ContentType(), nil
+}
+
+// rawData returns the codec bytes carried by Data
+func rawData(data interface{}) ([]byte, error) {
+	switch d := data.(type) {
+	case []byte:
+		return d, nil
+	case string:
+		return base64.StdEncoding.DecodeString(d)
+	case nil:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unexpected encoded data of type %T", data)
+}
+
+// decodeData decodes Data encoded with the codec registered for contentType
+// In strict mode the codec must be a StrictCodec
+func decodeData(contentType string, data interface{}, incoming interface{}, strict bool) error {
+	codec, err := codecFor(contentType)
+	if err != nil {
+		return err
+	}
+
+	raw, err := rawData(data)
+	if err != nil {
+		return err
+	}
+
+	if raw == nil {
+		return nil
+	}
+
+	if strict {
+		strictCodec, ok := codec.(StrictCodec)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrStrictUnsupported, contentType)
+		}
+
+		return strictCodec.UnmarshalStrict(raw, incoming)
+	}
+
+	return codec.Unmarshal(raw, incoming)
+}
+
+// SetCodec sets the codec used for outgoing requests and responses
+func (gom *Gommunicator) SetCodec(codec Codec) *Gommunicator {
+	RegisterCodec(codec)
+	gom.codec = codec
+	return gom
+}
+
+// SetActionCodec sets the codec used for requests to, and responses from, an action
+func (gom *Gommunicator) SetActionCodec(action string, codec Codec) *Gommunicator {
+	RegisterCodec(codec)
+
+	gom.codecsLock.Lock()
+	defer gom.codecsLock.Unlock()
+	gom.actionCodecs[action] = codec
+	return gom
+}
+
+func (gom *Gommunicator) actionCodec(action string) Codec {
+	gom.codecsLock.RLock()
+	defer gom.codecsLock.RUnlock()
+	return gom.actionCodecs[action]
+}
+
+// requestCodec picks the codec of an outgoing request
+func (gom *Gommunicator) requestCodec(input *ExecInput) Codec {
+	if input.Codec != nil {
+		return input.Codec
+	}
+
+	if codec := gom.actionCodec(input.Action); codec != nil {
+		return codec
+	}
+
+	return gom.codec
+}
+
+// responseCodec picks the codec of a response, replying in kind when the action has no codec set
+func (gom *Gommunicator) responseCodec(request *DataTransactionRequest) Codec {
+	if codec := gom.actionCodec(request.Action); codec != nil {
+		return codec
+	}
+
+	if !isJSON(request.ContentType) {
+		if codec, err := codecFor(request.ContentType); err == nil {
+			return codec
+		}
+	}
+
+	return gom.codec
+}
@@ -0,0 +1,137 @@
+// Package codecs provides binary gommunicator codecs
+//
+// Register the codecs a service may receive, and set the one it sends with:
+//
+//	gommunicator.RegisterCodec(codecs.CBOR)
+//	gom.SetCodec(codecs.MessagePack)
+package codecs
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/kelvne/gommunicator"
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Content types of the codecs
+const (
+	ContentTypeMessagePack = "application/msgpack"
+	ContentTypeCBOR        = "application/cbor"
+	ContentTypeProtobuf    = "application/protobuf"
+)
+
+type messagePack struct{}
+
+func (messagePack) ContentType() string {
+	return ContentTypeMessagePack
+}
+
+func (messagePack) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (messagePack) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
+
+func (messagePack) UnmarshalStrict(data []byte, v interface{}) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields(true)
+	return decoder.Decode(v)
+}
+
+type cborCodec struct{}
+
+func (cborCodec) ContentType() string {
+	return ContentTypeCBOR
+}
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return cbor.Marshal(v)
+}
+
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
+
+func (cborCodec) UnmarshalStrict(data []byte, v interface{}) error {
+	return strictCBOR.Unmarshal(data, v)
+}
+
+var strictCBOR, _ = cbor.DecOptions{ExtraReturnErrors: cbor.ExtraDecErrorUnknownField}.DecMode()
+
+type protobuf struct{}
+
+func (protobuf) ContentType() string {
+	return ContentTypeProtobuf
+}
+
+func (protobuf) Marshal(v interface{}) ([]byte, error) {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf codec can't marshal %T, it is not a proto.Message", v)
+	}
+
+	return proto.Marshal(message)
+}
+
+func (protobuf) Unmarshal(data []byte, v interface{}) error {
+	message, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf codec can't unmarshal into %T, it is not a proto.Message", v)
+	}
+
+	return proto.Unmarshal(data, message)
+}
+
+func (codec protobuf) UnmarshalStrict(data []byte, v interface{}) error {
+	if err := codec.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	// Fields unknown to the messages are kept aside by proto.Unmarshal
+	if hasUnknownFields(v.(proto.Message).ProtoReflect()) {
+		return fmt.Errorf("protobuf data has fields unknown to %T", v)
+	}
+
+	return nil
+}
+
+// hasUnknownFields reports if a message, or any message it holds, has unknown fields
+func hasUnknownFields(message protoreflect.Message) bool {
+	if len(message.GetUnknown()) > 0 {
+		return true
+	}
+
+	unknown := false
+	message.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		switch {
+		case field.IsList() && field.Message() != nil:
+			list := value.List()
+			for i := 0; i < list.Len() && !unknown; i++ {
+				unknown = hasUnknownFields(list.Get(i).Message())
+			}
+		case field.IsMap() && field.MapValue().Message() != nil:
+			value.Map().Range(func(key protoreflect.MapKey, item protoreflect.Value) bool {
+				unknown = hasUnknownFields(item.Message())
+				return !unknown
+			})
+		case field.Message() != nil && !field.IsMap():
+			unknown = hasUnknownFields(value.Message())
+		}
+		return !unknown
+	})
+
+	return unknown
+}
+
+// Codecs available, they all implement gommunicator.StrictCodec
+var (
+	MessagePack gommunicator.Codec = messagePack{}
+	CBOR        gommunicator.Codec = cborCodec{}
+	Protobuf    gommunicator.Codec = protobuf{}
+)
@@ -0,0 +1,162 @@
+package codecs
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/kelvne/gommunicator"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+type order struct {
+	ID    string   `msgpack:"id" cbor:"id"`
+	Items []string `msgpack:"items" cbor:"items"`
+}
+
+type orderID struct {
+	ID string `msgpack:"id" cbor:"id"`
+}
+
+// envelope carries encoded data through a request envelope, as it travels on SNS
+func envelope(t *testing.T, codec gommunicator.Codec, data interface{}) *gommunicator.DataTransactionRequest {
+	encoded, err := codec.Marshal(data)
+	if err != nil {
+		t.Fatalf("Marshal failed: %s", err.Error())
+	}
+
+	raw, _ := json.Marshal(&gommunicator.DataTransactionRequest{ContentType: codec.ContentType(), Data: encoded})
+	request := new(gommunicator.DataTransactionRequest)
+	if err := json.Unmarshal(raw, request); err != nil {
+		t.Fatalf("json.Unmarshal failed: %s", err.Error())
+	}
+
+	return request
+}
+
+func TestRoundTrip(t *testing.T) {
+	sent := order{ID: "42", Items: []string{"sku-1", "sku-2"}}
+
+	for _, codec := range []gommunicator.Codec{MessagePack, CBOR} {
+		gommunicator.RegisterCodec(codec)
+		request := envelope(t, codec, sent)
+
+		received := new(order)
+		if err := request.DecodeStrict(received); err != nil {
+			t.Fatalf("%s: DecodeStrict failed: %s", codec.ContentType(), err.Error())
+		}
+
+		if received.ID != sent.ID || len(received.Items) != 2 || received.Items[1] != "sku-2" {
+			t.Fatalf("%s: expected %+v, got %+v", codec.ContentType(), sent, received)
+		}
+
+		// Decode ignores the items, DecodeStrict rejects them
+		if err := request.Decode(new(orderID)); err != nil {
+			t.Fatalf("%s: Decode failed: %s", codec.ContentType(), err.Error())
+		}
+
+		if err := request.DecodeStrict(new(orderID)); err == nil {
+			t.Fatalf("%s: expected DecodeStrict to reject the unknown items", codec.ContentType())
+		}
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	gommunicator.RegisterCodec(Protobuf)
+
+	sent, _ := structpb.NewStruct(map[string]interface{}{"id": "42", "lines": []interface{}{map[string]interface{}{"sku": "sku-1"}}})
+	received := new(structpb.Struct)
+	if err := envelope(t, Protobuf, sent).DecodeStrict(received); err != nil {
+		t.Fatalf("DecodeStrict failed: %s", err.Error())
+	}
+
+	if !proto.Equal(sent, received) {
+		t.Fatalf("expected %v, got %v", sent, received)
+	}
+
+	request := envelope(t, Protobuf, wrapperspb.String("unknown to Empty"))
+	if err := request.Decode(new(emptypb.Empty)); err != nil {
+		t.Fatalf("Decode failed: %s", err.Error())
+	}
+
+	if err := request.DecodeStrict(new(emptypb.Empty)); err == nil {
+		t.Fatalf("expected DecodeStrict to reject the unknown field")
+	}
+}
+
+func TestPolicyConditions(t *testing.T) {
+	policy := &gommunicator.Policy{
+		Rules: []gommunicator.PolicyRule{
+			{Effect: gommunicator.Allow, Services: []string{"billing"}, Actions: []string{"accounts.*"}},
+			{Effect: gommunicator.Deny, Services: []string{"*"}, Actions: []string{"accounts.debit"}, Conditions: map[string]interface{}{"amount.currency": "XXX"}},
+		},
+	}
+
+	payload := map[string]interface{}{"amount": map[string]interface{}{"value": 10, "currency": "XXX"}}
+	for _, codec := range []gommunicator.Codec{CBOR, MessagePack} {
+		gommunicator.RegisterCodec(codec)
+		request := envelope(t, codec, payload)
+		request.IncomingService, request.Action = "billing", "accounts.debit"
+
+		if effect := policy.Evaluate(request); effect != gommunicator.Deny {
+			t.Fatalf("%s: expected the conditional deny rule to match, got %s", codec.ContentType(), effect)
+		}
+
+		other := envelope(t, codec, map[string]interface{}{"amount": map[string]interface{}{"value": 10, "currency": "EUR"}})
+		other.IncomingService, other.Action = "billing", "accounts.debit"
+		if effect := policy.Evaluate(other); effect != gommunicator.Allow {
+			t.Fatalf("%s: expected the conditions to be evaluated on the decoded data, got %s", codec.ContentType(), effect)
+		}
+	}
+
+	// Data the policy can't inspect is denied by conditional deny rules
+	gommunicator.RegisterCodec(Protobuf)
+	request := envelope(t, Protobuf, wrapperspb.String("XXX"))
+	request.IncomingService, request.Action = "billing", "accounts.debit"
+	if effect := policy.Evaluate(request); effect != gommunicator.Deny {
+		t.Fatalf("expected unreadable data to be denied, got %s", effect)
+	}
+
+	request.Action = "accounts.credit"
+	if effect := policy.Evaluate(request); effect != gommunicator.Allow {
+		t.Fatalf("expected rules without conditions to still apply, got %s", effect)
+	}
+}
+
+// publisher records the messages published to SNS
+type publisher struct {
+	snsiface.SNSAPI
+	messages []string
+}
+
+func (fake *publisher) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	fake.messages = append(fake.messages, aws.StringValue(input.Message))
+	return &sns.PublishOutput{}, nil
+}
+
+func TestRespondNil(t *testing.T) {
+	for _, codec := range []gommunicator.Codec{MessagePack, CBOR, Protobuf} {
+		topic := new(publisher)
+		gom := gommunicator.NewGommunicator(nil, topic, nil, "", "stock", "", "").SetLogState(false).SetCodec(codec)
+
+		actionID := "a-1"
+		request := &gommunicator.DataTransactionRequest{ID: "dt-1", Action: "stock.release", ActionID: &actionID, IncomingService: "orders"}
+		if err := gom.Respond(request, nil); err != nil {
+			t.Fatalf("%s: Respond failed: %s", codec.ContentType(), err.Error())
+		}
+
+		response := new(gommunicator.DataTransactionResponse)
+		if len(topic.messages) != 1 || json.Unmarshal([]byte(topic.messages[0]), response) != nil {
+			t.Fatalf("%s: expected a response to be published, got %v", codec.ContentType(), topic.messages)
+		}
+
+		if !response.Success || response.Data != nil || response.ContentType != "" {
+			t.Fatalf("%s: expected a response without data, got %+v", codec.ContentType(), response)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package compressors provides additional gommunicator compressors
+//
+// Every receiver must register the compressors its senders use:
+//
+//	gommunicator.RegisterCompressor(compressors.Zstd)
+//	gom.SetCompression(compressors.Zstd, 0)
+package compressors
+
+import (
+	"errors"
+
+	"github.com/kelvne/gommunicator"
+	"github.com/klauspost/compress/zstd"
+)
+
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (zstdCompressor) Encoding() string {
+	return "zstd"
+}
+
+func (compressor zstdCompressor) Compress(data []byte) ([]byte, error) {
+	return compressor.encoder.EncodeAll(data, nil), nil
+}
+
+func (compressor zstdCompressor) Decompress(data []byte) ([]byte, error) {
+	decompressed, err := compressor.decoder.DecodeAll(data, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return nil, gommunicator.ErrDecompressedTooLarge
+	}
+
+	return decompressed, err
+}
+
+func newZstd() gommunicator.Compressor {
+	// With valid options the encoder and decoder can't fail to build
+	encoder, _ := zstd.NewWriter(nil)
+	decoder, _ := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(gommunicator.MaxDecompressedSize))
+
+	return zstdCompressor{
+		encoder: encoder,
+		decoder: decoder,
+	}
+}
+
+// Zstd compresses bodies with zstandard, it is safe for concurrent use
+var Zstd = newZstd()
@@ -0,0 +1,51 @@
+package compressors
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/kelvne/gommunicator"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestCompressors(t *testing.T) {
+	body := bytes.Repeat([]byte(`{"order":"42","items":[1,2,3]}`), 1000)
+
+	for _, compressor := range []gommunicator.Compressor{gommunicator.GzipCompressor, Zstd} {
+		compressed, err := compressor.Compress(body)
+		if err != nil {
+			t.Fatalf("%s: Compress failed: %s", compressor.Encoding(), err.Error())
+		}
+
+		decompressed, err := compressor.Decompress(compressed)
+		if err != nil || !bytes.Equal(decompressed, body) {
+			t.Fatalf("%s: expected the body back, got %v", compressor.Encoding(), err)
+		}
+	}
+}
+
+func TestDecompressTooLarge(t *testing.T) {
+	body := make([]byte, gommunicator.MaxDecompressedSize+1)
+
+	for _, compressor := range []gommunicator.Compressor{gommunicator.GzipCompressor, Zstd} {
+		compressed, err := compressor.Compress(body)
+		if err != nil {
+			t.Fatalf("%s: Compress failed: %s", compressor.Encoding(), err.Error())
+		}
+
+		if _, err := compressor.Decompress(compressed); !errors.Is(err, gommunicator.ErrDecompressedTooLarge) {
+			t.Fatalf("%s: expected ErrDecompressedTooLarge, got %v", compressor.Encoding(), err)
+		}
+	}
+
+	// Streamed frames don't declare their size upfront
+	var streamed bytes.Buffer
+	writer, _ := zstd.NewWriter(&streamed)
+	writer.Write(body)
+	writer.Close()
+
+	if _, err := Zstd.Decompress(streamed.Bytes()); !errors.Is(err, gommunicator.ErrDecompressedTooLarge) {
+		t.Fatalf("expected streamed frames to be limited, got %v", err)
+	}
+}
@@ -6,8 +6,18 @@ import "sync"
 type Context struct {
 	Request *DataTransactionRequest
 
-	store map[string]interface{}
-	lock  sync.RWMutex
+	gom     *Gommunicator
+	store   map[string]interface{}
+	headers map[string]string
+	lock    sync.RWMutex
+}
+
+func newContext(gom *Gommunicator, request *DataTransactionRequest) *Context {
+	return &Context{
+		Request: request,
+		gom:     gom,
+		headers: copyHeaders(request.Headers),
+	}
 }
 
 // Set sets a new value on the context store
@@ -28,3 +38,71 @@ func (ctx *Context) Get(key string) interface{} {
 	defer ctx.lock.RUnlock()
 	return ctx.store[key]
 }
+
+// Header retrieves a header of the request, or the value set on this context
+func (ctx *Context) Header(key string) string {
+	ctx.lock.RLock()
+	defer ctx.lock.RUnlock()
+	return ctx.headers[key]
+}
+
+// Headers returns a copy of the context headers
+func (ctx *Context) Headers() map[string]string {
+	ctx.lock.RLock()
+	defer ctx.lock.RUnlock()
+	return copyHeaders(ctx.headers)
+}
+
+// SetHeader sets a header propagated to the nested calls and to the responses sent through this context
+func (ctx *Context) SetHeader(key, value string) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+
+	if ctx.headers == nil {
+		ctx.headers = make(map[string]string)
+	}
+
+	ctx.headers[key] = value
+}
+
+// TenantID returns the HeaderTenantID header
+func (ctx *Context) TenantID() string {
+	return ctx.Header(HeaderTenantID)
+}
+
+// UserID returns the HeaderUserID header
+func (ctx *Context) UserID() string {
+	return ctx.Header(HeaderUserID)
+}
+
+// Locale returns the HeaderLocale header
+func (ctx *Context) Locale() string {
+	return ctx.Header(HeaderLocale)
+}
+
+// Exec executes a nested action propagating the context headers, data transaction and trace
+// Headers set on the input override the context ones
+func (ctx *Context) Exec(input *ExecInput) (<-chan *DataTransactionResponse, error) {
+	nested := *input
+	nested.Headers = mergeHeaders(ctx.Headers(), input.Headers)
+
+	if nested.DataTransactionID == "" {
+		nested.DataTransactionID = ctx.Request.ID
+	}
+
+	if nested.Context == nil {
+		nested.Context = ctx.Request.Context()
+	}
+
+	return ctx.gom.Exec(&nested)
+}
+
+// Respond responds the request with the context headers
+func (ctx *Context) Respond(payload interface{}) error {
+	return ctx.gom.respondWithHeaders(ctx.Request, payload, ctx.Headers())
+}
+
+// RespondError responds the request with an error and the context headers
+func (ctx *Context) RespondError(mapErr MapErr) error {
+	return ctx.gom.respondErrorWithHeaders(ctx.Request, mapErr, ctx.Headers())
+}
@@ -1,6 +1,7 @@
 package gommunicator
 
 import (
+	"context"
 	"fmt"
 	"strings"
 )
@@ -15,8 +16,15 @@ type DataTransactionRequest struct {
 	Data    interface{} `json:"data"`    // Payload to be read
 	Timeout int         `json:"timeout"` // Timeout policy in seconds
 
-	IncomingService string  `json:"incomingService"` // The name of the service requesting
-	ActionID        *string `json:"actionId"`        // ActionID represents the internal id for atomic internal request/response
+	IncomingService string  `json:"incomingService"`       // The name of the service requesting
+	ActionID        *string `json:"actionId"`              // ActionID represents the internal id for atomic internal request/response
+	ContentType     string  `json:"contentType,omitempty"` // Codec content type of Data, empty means JSON
+
+	ClaimCheck *ClaimCheck       `json:"claimCheck,omitempty"` // Reference to Data when it was offloaded to a BlobStore
+	Headers    map[string]string `json:"headers,omitempty"`    // Metadata such as tenant, user and locale
+	NoResponse bool              `json:"noResponse,omitempty"` // Nothing waits for the response, Respond sends none
+
+	ctx context.Context // Context of the request handling, carries its trace span
 }
 
 // Decode is a helper method for transforming incoming data
@@ -33,6 +41,11 @@ func (dt *DataTransactionRequest) Decode(incoming interface{}) error {
 	return DecodeRequest(dt, incoming)
 }
 
+// DecodeStrict is Decode rejecting fields unknown to incoming
+func (dt *DataTransactionRequest) DecodeStrict(incoming interface{}) error {
+	return DecodeRequestStrict(dt, incoming)
+}
+
 // DataTransactionResponse is the response object to the services cluster
 type DataTransactionResponse struct {
 	DedupID string `json:"dedupId"` // Prevent duplication ID
@@ -44,7 +57,13 @@ type DataTransactionResponse struct {
 	Message string      `json:"message"` // Message to be read
 	Data    interface{} `json:"data"`    // Payload to be read
 
-	ActionID *string `json:"actionId"` // ActionID represents the internal id for atomic internal request/response
+	ActionID    *string `json:"actionId"`              // ActionID represents the internal id for atomic internal request/response
+	ContentType string  `json:"contentType,omitempty"` // Codec content type of Data, empty means JSON
+
+	ClaimCheck *ClaimCheck       `json:"claimCheck,omitempty"` // Reference to Data when it was offloaded to a BlobStore
+	Headers    map[string]string `json:"headers,omitempty"`    // Metadata such as tenant, user and locale
+
+	Error *RemoteError `json:"error,omitempty"` // Structured error of a failed response, see Err
 }
 
 // Decode is a helper method for transforming incoming data
@@ -61,6 +80,11 @@ func (dt *DataTransactionResponse) Decode(incoming interface{}) error {
 	return DecodeResponse(dt, incoming)
 }
 
+// DecodeStrict is Decode rejecting fields unknown to incoming
+func (dt *DataTransactionResponse) DecodeStrict(incoming interface{}) error {
+	return DecodeResponseStrict(dt, incoming)
+}
+
 // DataTransaction holder for handling data transactions
 type DataTransaction struct {
 	id       string
@@ -161,5 +185,6 @@ func (transaction *DataTransaction) FailFromMapErr(err MapErr) *DataTransactionR
 		ActionID: transaction.actionID,
 		Title:    string(err.GetType()),
 		Action:   transaction.action,
+		Error:    NewRemoteError(err),
 	}
 }
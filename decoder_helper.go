@@ -1,84 +1,311 @@
 package gommunicator
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-func decode(data interface{}, incoming interface{}) error {
-	baseData := reflect.ValueOf(data)
-	incomingType := reflect.TypeOf(incoming)
-	structType := incomingType.Elem()
+// DecodeError is returned when data can't be decoded, Path locates the failing field (items[1].sku)
+type DecodeError struct {
+	Path string
+	Err  error
+}
 
-	if incomingType.Kind() != reflect.Ptr {
-		return errors.New("invalid incoming object")
+func (err *DecodeError) Error() string {
+	if err.Path == "" {
+		return fmt.Sprintf("decode: %s", err.Err.Error())
 	}
 
-	if structType.Kind() == reflect.Slice {
-		structType = structType.Elem().Elem()
+	return fmt.Sprintf("decode %s: %s", err.Path, err.Err.Error())
+}
+
+// Unwrap returns the underlying error
+func (err *DecodeError) Unwrap() error {
+	return err.Err
+}
+
+// joinPath appends a field or an index to a path
+func joinPath(path, field string) string {
+	if strings.HasPrefix(field, "[") || path == "" {
+		return path + field
 	}
 
-	if baseData.Kind() == reflect.Ptr {
-		baseData = reflect.ValueOf(baseData.Elem())
+	return path + "." + field
+}
+
+// jsonPath turns an encoding/json field path (items.1.sku) into a decode path (items[1].sku)
+func jsonPath(path, field string) string {
+	for _, segment := range strings.Split(field, ".") {
+		if segment == "" {
+			continue
+		}
+
+		if _, err := strconv.Atoi(segment); err == nil {
+			segment = "[" + segment + "]"
+		}
+
+		path = joinPath(path, segment)
 	}
 
-	if !baseData.IsValid() {
-		return nil
+	return path
+}
+
+var jsonUnmarshaler = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// structField finds the field of a struct type decoding a JSON key, following encoding/json rules
+func structField(structType reflect.Type, key string) (reflect.StructField, bool) {
+	var folded *reflect.StructField
+
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.SplitN(tag, ",", 2)[0]
+
+		if name == "-" && !strings.Contains(tag, ",") {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				if found, ok := structField(embedded, key); ok {
+					return found, true
+				}
+				continue
+			}
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if name == key {
+			return field, true
+		}
+
+		if folded == nil && strings.EqualFold(name, key) {
+			folded = &field
+		}
+	}
+
+	if folded != nil {
+		return *folded, true
+	}
+
+	return reflect.StructField{}, false
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// unknownField walks JSON decoded data alongside the target type looking for a field the type doesn't have
+func unknownField(value interface{}, targetType reflect.Type, path string) (string, bool) {
+	for targetType.Kind() == reflect.Ptr {
+		targetType = targetType.Elem()
 	}
 
-	switch baseData.Kind() {
-	case reflect.Array, reflect.Slice:
-		if incomingType.Elem().Kind() != reflect.Slice && incomingType.Elem().Kind() != reflect.Array {
-			return errors.New("trying to parse a slice to a single object")
+	if reflect.PtrTo(targetType).Implements(jsonUnmarshaler) {
+		return "", false
+	}
+
+	switch targetType.Kind() {
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
 		}
 
-		incomingValue := reflect.ValueOf(incoming).Elem()
+		for _, key := range sortedKeys(object) {
+			field, ok := structField(targetType, key)
+			if !ok {
+				return joinPath(path, key), true
+			}
 
-		for i := 0; i < baseData.Len(); i++ {
-			marshaled, err := json.Marshal(baseData.Index(i).Interface())
-			if err != nil {
-				return err
+			if found, ok := unknownField(object[key], field.Type, joinPath(path, key)); ok {
+				return found, true
 			}
-			toParse := reflect.New(structType).Interface()
-			err = json.Unmarshal(marshaled, toParse)
-			if err != nil {
-				return fmt.Errorf("error while unmarshaling value to slice item: %s", err.Error())
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+
+		for _, key := range sortedKeys(object) {
+			if found, ok := unknownField(object[key], targetType.Elem(), joinPath(path, key)); ok {
+				return found, true
 			}
+		}
+	case reflect.Slice, reflect.Array:
+		items, ok := value.([]interface{})
+		if !ok {
+			return "", false
+		}
 
-			incomingValue.Set(reflect.Append(incomingValue, reflect.ValueOf(toParse)))
-			return nil
+		for i, item := range items {
+			if found, ok := unknownField(item, targetType.Elem(), joinPath(path, fmt.Sprintf("[%d]", i))); ok {
+				return found, true
+			}
 		}
+	}
 
-	case reflect.Struct, reflect.Map:
-		incomingValue := reflect.ValueOf(incoming)
+	return "", false
+}
 
-		marshaled, err := json.Marshal(data)
-		if err != nil {
-			return err
+// unmarshal decodes raw JSON into target, reporting the path of the failing field
+func unmarshal(raw []byte, target reflect.Value, path string, strict bool) error {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(target.Interface())
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &DecodeError{
+			Path: jsonPath(path, typeErr.Field),
+			Err:  fmt.Errorf("cannot decode %s into %s", typeErr.Value, typeErr.Type.String()),
+		}
+	}
+
+	// The unknown field error of encoding/json has no type, the data is walked to locate it
+	if strict {
+		var generic interface{}
+		if json.Unmarshal(raw, &generic) == nil {
+			if field, ok := unknownField(generic, target.Type(), path); ok {
+				return &DecodeError{Path: field, Err: errors.New("unknown field")}
+			}
 		}
+	}
+
+	return &DecodeError{Path: path, Err: err}
+}
+
+// decodeValue decodes raw JSON into the value target points to
+// Slices are decoded item by item so errors carry the item index, byte slices are base64 strings
+func decodeValue(raw json.RawMessage, target reflect.Value, path string, strict bool) error {
+	elem := target.Elem()
 
-		if incomingValue.IsZero() {
-			val := reflect.New(structType.Elem())
-			incoming = val.Interface()
+	if elem.Kind() != reflect.Slice || elem.Type().Elem().Kind() == reflect.Uint8 || reflect.PtrTo(elem.Type()).Implements(jsonUnmarshaler) {
+		return unmarshal(raw, target, path, strict)
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return &DecodeError{
+			Path: path,
+			Err:  fmt.Errorf("cannot decode %s", elem.Type().String()),
+		}
+	}
+
+	if items == nil {
+		return nil
+	}
+
+	slice := reflect.MakeSlice(elem.Type(), 0, len(items))
+	for i, item := range items {
+		value := reflect.New(elem.Type().Elem())
+		if err := decodeValue(item, value, joinPath(path, fmt.Sprintf("[%d]", i)), strict); err != nil {
+			return err
 		}
 
-		err = json.Unmarshal(marshaled, incomingValue.Interface())
-		return err
-	default:
-		return errors.New("cant parse directly this data type, use the receiver channel and decode by yourself")
+		slice = reflect.Append(slice, value.Elem())
 	}
 
+	elem.Set(slice)
 	return nil
 }
 
+// decodeWith decodes data into incoming, which must be a non nil pointer
+// Pointers to structs, maps, slices (of values or pointers) and scalars are supported
+// In strict mode fields unknown to the target are rejected
+func decodeWith(data interface{}, incoming interface{}, strict bool) error {
+	target := reflect.ValueOf(incoming)
+	if !target.IsValid() || target.Kind() != reflect.Ptr {
+		return fmt.Errorf("invalid incoming object, expected a pointer got %T", incoming)
+	}
+
+	if data == nil {
+		return nil
+	}
+
+	if target.IsNil() {
+		return fmt.Errorf("invalid incoming object, nil %T", incoming)
+	}
+
+	raw, ok := data.(json.RawMessage)
+	if !ok {
+		marshaled, err := json.Marshal(data)
+		if err != nil {
+			return &DecodeError{Err: err}
+		}
+		raw = marshaled
+	}
+
+	return decodeValue(raw, target, "", strict)
+}
+
+func decode(data interface{}, incoming interface{}) error {
+	return decodeWith(data, incoming, false)
+}
+
 // DecodeRequest decodes the data of a request to a incoming struct or slice of
+// Data encoded with a non JSON codec is decoded straight by the codec
 func DecodeRequest(dt *DataTransactionRequest, incoming interface{}) error {
+	if !isJSON(dt.ContentType) {
+		return decodeData(dt.ContentType, dt.Data, incoming, false)
+	}
 	return decode(dt.Data, incoming)
 }
 
+// DecodeRequestStrict decodes the data of a request rejecting fields unknown to incoming
+// Data encoded with a non JSON codec requires a StrictCodec
+func DecodeRequestStrict(dt *DataTransactionRequest, incoming interface{}) error {
+	if !isJSON(dt.ContentType) {
+		return decodeData(dt.ContentType, dt.Data, incoming, true)
+	}
+	return decodeWith(dt.Data, incoming, true)
+}
+
 // DecodeResponse decodes the data of a response to a incoming struct or slice of
+// Data encoded with a non JSON codec is decoded straight by the codec
 func DecodeResponse(dt *DataTransactionResponse, incoming interface{}) error {
+	if !isJSON(dt.ContentType) {
+		return decodeData(dt.ContentType, dt.Data, incoming, false)
+	}
 	return decode(dt.Data, incoming)
 }
+
+// DecodeResponseStrict decodes the data of a response rejecting fields unknown to incoming
+// Data encoded with a non JSON codec requires a StrictCodec
+func DecodeResponseStrict(dt *DataTransactionResponse, incoming interface{}) error {
+	if !isJSON(dt.ContentType) {
+		return decodeData(dt.ContentType, dt.Data, incoming, true)
+	}
+	return decodeWith(dt.Data, incoming, true)
+}
@@ -1,6 +1,7 @@
 package gommunicator
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -80,3 +81,106 @@ func TestDecodeSlice(t *testing.T) {
 		fDecodeValue(t, rq.Data)
 	}
 }
+
+type Item struct {
+	SKU      string `json:"sku"`
+	Quantity int    `json:"quantity"`
+}
+
+type Order struct {
+	Items []Item `json:"items"`
+}
+
+func TestDecodeSliceOfValues(t *testing.T) {
+	rq := DataTransactionRequest{
+		Data: []interface{}{
+			map[string]interface{}{"sku": "a", "quantity": 1},
+			map[string]interface{}{"sku": "b", "quantity": 2},
+		},
+	}
+
+	items := make([]Item, 0)
+	if err := DecodeRequest(&rq, &items); err != nil {
+		fDecode(t, err)
+	}
+
+	if !reflect.DeepEqual(items, []Item{{"a", 1}, {"b", 2}}) {
+		fDecodeValue(t, items)
+	}
+}
+
+func TestDecodeScalar(t *testing.T) {
+	rq := DataTransactionRequest{Data: 42}
+
+	var number int
+	if err := DecodeRequest(&rq, &number); err != nil {
+		fDecode(t, err)
+	}
+
+	if number != 42 {
+		fDecodeValue(t, number)
+	}
+}
+
+func TestDecodeErrorPath(t *testing.T) {
+	rq := DataTransactionRequest{
+		Data: map[string]interface{}{
+			"items": []interface{}{
+				map[string]interface{}{"sku": "a", "quantity": 1},
+				map[string]interface{}{"sku": "b", "quantity": "two"},
+			},
+		},
+	}
+
+	var decodeErr *DecodeError
+
+	err := DecodeRequest(&rq, new(Order))
+	if !errors.As(err, &decodeErr) || decodeErr.Path != "items[1].quantity" {
+		t.Fatalf("expected a decode error on items[1].quantity, got %v", err)
+	}
+
+	rq.Data = []interface{}{map[string]interface{}{"sku": "a", "color": "red"}}
+
+	if err := DecodeRequest(&rq, &[]Item{}); err != nil {
+		fDecode(t, err)
+	}
+
+	err = DecodeRequestStrict(&rq, &[]Item{})
+	if !errors.As(err, &decodeErr) || decodeErr.Path != "[0].color" {
+		t.Fatalf("expected an unknown field error on [0].color, got %v", err)
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	var raw []byte
+	if err := decode("aGVsbG8=", &raw); err != nil {
+		fDecode(t, err)
+	}
+
+	if string(raw) != "hello" {
+		t.Fatalf("expected base64 data decoded into bytes, got %q", raw)
+	}
+
+	var payloads [][]byte
+	if err := decode([]interface{}{"aGVsbG8="}, &payloads); err != nil {
+		fDecode(t, err)
+	}
+
+	if len(payloads) != 1 || string(payloads[0]) != "hello" {
+		t.Fatalf("expected a slice of byte slices, got %q", payloads)
+	}
+}
+
+func TestDecodeStrictCodec(t *testing.T) {
+	RegisterCodec(jsonTextCodec{})
+	rq := DataTransactionRequest{ContentType: jsonTextCodec{}.ContentType(), Data: []byte(`{"msg":"hello"}`)}
+
+	expected := new(Expected)
+	if err := rq.Decode(expected); err != nil || expected.Message != "hello" {
+		t.Fatalf("expected the codec to decode the data, got %v", err)
+	}
+
+	if err := rq.DecodeStrict(new(Expected)); !errors.Is(err, ErrStrictUnsupported) {
+		t.Fatalf("expected ErrStrictUnsupported, got %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DescribeAction is the reserved action every Gommunicator answers with its ServiceDescription
+const DescribeAction = "__describe"
+
+// reservedPrefix marks internal actions, they are not listed on descriptions
+const reservedPrefix = "__"
+
+// ActionDescription describes a registered action
+type ActionDescription struct {
+	Action       string          `json:"action"`                 // Registered action, including version
+	Name         string          `json:"name"`                   // Action name without version
+	Version      string          `json:"version,omitempty"`      // Action version (v2.0)
+	Pattern      bool            `json:"pattern"`                // True if the action is a wildcard pattern
+	InputSchema  json.RawMessage `json:"inputSchema,omitempty"`  // JSON schema of the request data
+	OutputSchema json.RawMessage `json:"outputSchema,omitempty"` // JSON schema of the response data
+}
+
+// BuildInfo describes the binary running a service
+type BuildInfo struct {
+	GoVersion string `json:"goVersion"`
+	Path      string `json:"path,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Sum       string `json:"sum,omitempty"`
+}
+
+// ServiceDescription is the answer to the DescribeAction
+type ServiceDescription struct {
+	Service string              `json:"service"`
+	Actions []ActionDescription `json:"actions"`
+	Build   BuildInfo           `json:"build"`
+}
+
+type actionSchemas struct {
+	input  json.RawMessage
+	output json.RawMessage
+}
+
+// schemaStore holds the declared schemas per registered action
+type schemaStore struct {
+	lock    sync.RWMutex
+	schemas map[string]actionSchemas
+}
+
+func newSchemaStore() *schemaStore {
+	return &schemaStore{schemas: make(map[string]actionSchemas)}
+}
+
+func (store *schemaStore) set(action string, schemas actionSchemas) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.schemas[action] = schemas
+}
+
+func (store *schemaStore) get(action string) actionSchemas {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return store.schemas[action]
+}
+
+func rawSchema(schema interface{}) (json.RawMessage, error) {
+	switch s := schema.(type) {
+	case nil:
+		return nil, nil
+	case json.RawMessage:
+		return s, nil
+	case []byte:
+		return json.RawMessage(s), nil
+	case string:
+		return json.RawMessage(s), nil
+	}
+
+	return json.Marshal(schema)
+}
+
+// SetActionSchemas declares the input/output JSON schemas of an action
+// Schemas may be a JSON string, raw bytes or any value marshaled to JSON, nil means not declared
+func (gom *Gommunicator) SetActionSchemas(action string, input, output interface{}) *Gommunicator {
+	in, err := rawSchema(input)
+	if err != nil {
+		gom.onErr(err)
+		return gom
+	}
+
+	out, err := rawSchema(output)
+	if err != nil {
+		gom.onErr(err)
+		return gom
+	}
+
+	gom.schemas.set(action, actionSchemas{input: in, output: out})
+	return gom
+}
+
+func (r *router) list() []*route {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	routes := make([]*route, 0, len(r.exact)+len(r.patterns))
+	for _, versions := range r.exact {
+		routes = append(routes, versions...)
+	}
+
+	routes = append(routes, r.patterns...)
+	return routes
+}
+
+func buildInfo() BuildInfo {
+	info := BuildInfo{GoVersion: runtime.Version()}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Path = bi.Main.Path
+		info.Version = bi.Main.Version
+		info.Sum = bi.Main.Sum
+	}
+
+	return info
+}
+
+// Description returns the description of this service
+func (gom *Gommunicator) Description() *ServiceDescription {
+	actions := make([]ActionDescription, 0)
+
+	for _, rt := range gom.actions.list() {
+		if strings.HasPrefix(rt.name, reservedPrefix) {
+			continue
+		}
+
+		schemas := gom.schemas.get(rt.action)
+		actions = append(actions, ActionDescription{
+			Action:       rt.action,
+			Name:         rt.name,
+			Version:      rt.version.String(),
+			Pattern:      isPattern(rt.name),
+			InputSchema:  schemas.input,
+			OutputSchema: schemas.output,
+		})
+	}
+
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Action < actions[j].Action
+	})
+
+	return &ServiceDescription{
+		Service: gom.ServiceName,
+		Actions: actions,
+		Build:   buildInfo(),
+	}
+}
+
+func (gom *Gommunicator) describeHandler(request *DataTransactionRequest) error {
+	return gom.Respond(request, gom.Description())
+}
+
+// Describe asks a service for its ServiceDescription through the DescribeAction
+func (gom *Gommunicator) Describe(service string, timeout int) (*ServiceDescription, error) {
+	receiver, err := gom.Exec(&ExecInput{
+		Action:  DescribeAction,
+		Service: service,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := <-receiver
+	if response == nil {
+		return nil, fmt.Errorf("describe %s timed out", service)
+	}
+
+	if !response.Success {
+		return nil, errors.New(response.Message)
+	}
+
+	description := new(ServiceDescription)
+	if err := response.Decode(description); err != nil {
+		return nil, err
+	}
+
+	return description, nil
+}
+
+// Catalog describes every given service concurrently
+// Services failing to answer are reported on the returned error, the others are still listed
+func (gom *Gommunicator) Catalog(timeout int, services ...string) (map[string]*ServiceDescription, error) {
+	catalog := make(map[string]*ServiceDescription)
+	failures := make([]string, 0)
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, service := range services {
+		wg.Add(1)
+		go func(service string) {
+			defer wg.Done()
+			description, err := gom.Describe(service, timeout)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %s", service, err.Error()))
+				return
+			}
+			catalog[service] = description
+		}(service)
+	}
+
+	wg.Wait()
+
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return catalog, fmt.Errorf("catalog incomplete: %s", strings.Join(failures, "; "))
+	}
+
+	return catalog, nil
+}
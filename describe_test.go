@@ -0,0 +1,53 @@
+package gommunicator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDescription(t *testing.T) {
+	gom := NewGommunicator(nil, nil, nil, "", "stock", "", "").SetLogState(false)
+	handler := func(request *DataTransactionRequest) error { return nil }
+	gom.RegisterAction("stock.reserve@v2.1", handler).
+		RegisterAction("stock.events.*", handler).
+		SetActionSchemas("stock.reserve@v2.1", `{"type":"object"}`, map[string]string{"type": "string"})
+
+	description := gom.Description()
+	if description.Service != "stock" || description.Build.GoVersion == "" {
+		t.Fatalf("expected the service and its build, got %+v", description)
+	}
+
+	if len(description.Actions) != 2 {
+		t.Fatalf("expected the reserved actions to be hidden, got %+v", description.Actions)
+	}
+
+	events, reserve := description.Actions[0], description.Actions[1]
+	if events.Action != "stock.events.*" || !events.Pattern || events.InputSchema != nil {
+		t.Fatalf("unexpected pattern description %+v", events)
+	}
+
+	if reserve.Name != "stock.reserve" || reserve.Version == "" || reserve.Pattern ||
+		string(reserve.InputSchema) != `{"type":"object"}` || string(reserve.OutputSchema) != `{"type":"string"}` {
+		t.Fatalf("unexpected action description %+v", reserve)
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	cluster := newFakeCluster()
+	orders := cluster.service("orders")
+	cluster.service("stock").RegisterAction("stock.reserve", func(request *DataTransactionRequest) error { return nil })
+
+	catalog, err := orders.Catalog(1, "stock", "missing")
+	if err == nil || !strings.Contains(err.Error(), "missing: describe missing timed out") {
+		t.Fatalf("expected the missing service to be reported, got %v", err)
+	}
+
+	stock, ok := catalog["stock"]
+	if !ok || len(catalog) != 1 {
+		t.Fatalf("expected only stock on the catalog, got %v", catalog)
+	}
+
+	if len(stock.Actions) != 1 || stock.Actions[0].Action != "stock.reserve" {
+		t.Fatalf("expected stock to describe its actions, got %+v", stock.Actions)
+	}
+}
@@ -0,0 +1,393 @@
+package gommunicator
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// dataKeySize is the size of the AES-256 data keys
+const dataKeySize = 32
+
+// DefaultDataKeyTTL is how long a data key is reused before a new one is generated
+const DefaultDataKeyTTL = 5 * time.Minute
+
+// KeyProvider provides the data keys used on envelope encryption
+type KeyProvider interface {
+	// GenerateDataKey returns a new data key, in plaintext and wrapped by the master key keyID
+	GenerateDataKey() (keyID string, plaintext, wrapped []byte, err error)
+	// DecryptDataKey unwraps a data key wrapped by the master key keyID
+	DecryptDataKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// sealOverhead is what sealAESGCM adds to the plaintext, its nonce and tag
+const sealOverhead = 12 + 16
+
+func sealAESGCM(key, plaintext, additional []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func openAESGCM(key, sealed, additional []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted message too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, additional)
+}
+
+// KMSKeyProvider is a KeyProvider generating data keys with AWS KMS
+// Rotation of the master key is handled by KMS itself
+type KMSKeyProvider struct {
+	client *kms.KMS
+	keyID  string
+}
+
+// NewKMSKeyProvider returns a new KMSKeyProvider using the KMS key (id, ARN or alias)
+func NewKMSKeyProvider(client *kms.KMS, keyID string) *KMSKeyProvider {
+	return &KMSKeyProvider{
+		client: client,
+		keyID:  keyID,
+	}
+}
+
+// GenerateDataKey returns a new data key
+func (provider *KMSKeyProvider) GenerateDataKey() (string, []byte, []byte, error) {
+	output, err := provider.client.GenerateDataKey(&kms.GenerateDataKeyInput{
+		KeyId:   aws.String(provider.keyID),
+		KeySpec: aws.String(kms.DataKeySpecAes256),
+	})
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return aws.StringValue(output.KeyId), output.Plaintext, output.CiphertextBlob, nil
+}
+
+// DecryptDataKey unwraps a data key
+func (provider *KMSKeyProvider) DecryptDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	output, err := provider.client.Decrypt(&kms.DecryptInput{
+		KeyId:          aws.String(keyID),
+		CiphertextBlob: wrapped,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Plaintext, nil
+}
+
+// StaticKeyProvider is a KeyProvider wrapping data keys with local AES-256 master keys
+// Keys are rotated by adding a new key and making it current, older keys keep decrypting
+type StaticKeyProvider struct {
+	lock    sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider returns a new StaticKeyProvider with a single current master key
+func NewStaticKeyProvider(keyID string, key []byte) (*StaticKeyProvider, error) {
+	provider := &StaticKeyProvider{keys: make(map[string][]byte)}
+	if err := provider.AddKey(keyID, key); err != nil {
+		return nil, err
+	}
+
+	return provider, provider.SetCurrent(keyID)
+}
+
+// AddKey adds a master key
+func (provider *StaticKeyProvider) AddKey(keyID string, key []byte) error {
+	if len(key) != dataKeySize {
+		return fmt.Errorf("master key %s must have %d bytes", keyID, dataKeySize)
+	}
+
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	provider.keys[keyID] = key
+	return nil
+}
+
+// SetCurrent sets the master key wrapping new data keys
+func (provider *StaticKeyProvider) SetCurrent(keyID string) error {
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+
+	if _, ok := provider.keys[keyID]; !ok {
+		return fmt.Errorf("unknown master key %s", keyID)
+	}
+
+	provider.current = keyID
+	return nil
+}
+
+func (provider *StaticKeyProvider) key(keyID string) ([]byte, error) {
+	provider.lock.RLock()
+	defer provider.lock.RUnlock()
+
+	key, ok := provider.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %s", keyID)
+	}
+
+	return key, nil
+}
+
+// GenerateDataKey returns a new data key wrapped by the current master key
+func (provider *StaticKeyProvider) GenerateDataKey() (string, []byte, []byte, error) {
+	provider.lock.RLock()
+	keyID := provider.current
+	provider.lock.RUnlock()
+
+	master, err := provider.key(keyID)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	plaintext := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, plaintext); err != nil {
+		return "", nil, nil, err
+	}
+
+	wrapped, err := sealAESGCM(master, plaintext, []byte(keyID))
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return keyID, plaintext, wrapped, nil
+}
+
+// DecryptDataKey unwraps a data key
+func (provider *StaticKeyProvider) DecryptDataKey(keyID string, wrapped []byte) ([]byte, error) {
+	master, err := provider.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return openAESGCM(master, wrapped, []byte(keyID))
+}
+
+// keyFile is the layout of a key file
+//
+//	{"current": "2020-06", "keys": {"2020-05": "<base64 key>", "2020-06": "<base64 key>"}}
+type keyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// FileKeyProvider is a StaticKeyProvider loaded from a JSON key file
+type FileKeyProvider struct {
+	*StaticKeyProvider
+	path string
+}
+
+// NewFileKeyProvider returns a new FileKeyProvider loading the key file at path
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	provider := &FileKeyProvider{
+		StaticKeyProvider: &StaticKeyProvider{keys: make(map[string][]byte)},
+		path:              path,
+	}
+
+	return provider, provider.Reload()
+}
+
+// Reload reloads the key file, used to rotate keys without restarting
+func (provider *FileKeyProvider) Reload() error {
+	content, err := ioutil.ReadFile(provider.path)
+	if err != nil {
+		return err
+	}
+
+	file := new(keyFile)
+	if err := json.Unmarshal(content, file); err != nil {
+		return err
+	}
+
+	keys := make(map[string][]byte)
+	for keyID, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return fmt.Errorf("master key %s: %s", keyID, err.Error())
+		}
+
+		if len(key) != dataKeySize {
+			return fmt.Errorf("master key %s must have %d bytes", keyID, dataKeySize)
+		}
+
+		keys[keyID] = key
+	}
+
+	if _, ok := keys[file.Current]; !ok {
+		return fmt.Errorf("unknown current master key %s", file.Current)
+	}
+
+	provider.lock.Lock()
+	defer provider.lock.Unlock()
+	provider.keys = keys
+	provider.current = file.Current
+	return nil
+}
+
+// dataKey is a data key in use
+type dataKey struct {
+	keyID     string
+	plaintext []byte
+	wrapped   []byte
+	expiresAt time.Time
+}
+
+// encrypter encrypts message bodies caching the data keys to spare the KeyProvider
+type encrypter struct {
+	provider KeyProvider
+	ttl      time.Duration
+
+	lock      sync.Mutex
+	current   *dataKey
+	unwrapped map[string][]byte
+}
+
+func newEncrypter(provider KeyProvider, ttl time.Duration) *encrypter {
+	return &encrypter{
+		provider:  provider,
+		ttl:       ttl,
+		unwrapped: make(map[string][]byte),
+	}
+}
+
+func (enc *encrypter) dataKey() (*dataKey, error) {
+	enc.lock.Lock()
+	defer enc.lock.Unlock()
+
+	if enc.current != nil && time.Now().Before(enc.current.expiresAt) {
+		return enc.current, nil
+	}
+
+	keyID, plaintext, wrapped, err := enc.provider.GenerateDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	enc.current = &dataKey{
+		keyID:     keyID,
+		plaintext: plaintext,
+		wrapped:   wrapped,
+		expiresAt: time.Now().Add(enc.ttl),
+	}
+
+	return enc.current, nil
+}
+
+func (enc *encrypter) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	cacheKey := keyID + ":" + string(wrapped)
+
+	enc.lock.Lock()
+	plaintext, ok := enc.unwrapped[cacheKey]
+	enc.lock.Unlock()
+
+	if ok {
+		return plaintext, nil
+	}
+
+	plaintext, err := enc.provider.DecryptDataKey(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	enc.lock.Lock()
+	defer enc.lock.Unlock()
+
+	// Senders rotate data keys, so the cache is simply dropped once it grows
+	if len(enc.unwrapped) >= 1024 {
+		enc.unwrapped = make(map[string][]byte)
+	}
+	enc.unwrapped[cacheKey] = plaintext
+
+	return plaintext, nil
+}
+
+// seal encrypts data with the current data key, returning the key ID and the base64 wrapped data key
+func (enc *encrypter) seal(data []byte) (sealed []byte, keyID, wrappedKey string, err error) {
+	key, err := enc.dataKey()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	sealed, err = sealAESGCM(key.plaintext, data, []byte(key.keyID))
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return sealed, key.keyID, base64.StdEncoding.EncodeToString(key.wrapped), nil
+}
+
+func (enc *encrypter) encrypt(body []byte, attributes map[string]*sns.MessageAttributeValue) ([]byte, error) {
+	sealed, keyID, wrappedKey, err := enc.seal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes["EncryptionKeyID"] = stringAttribute(keyID)
+	attributes["EncryptedDataKey"] = stringAttribute(wrappedKey)
+	return sealed, nil
+}
+
+func (enc *encrypter) decrypt(sealed []byte, keyID, wrappedKey string) ([]byte, error) {
+	wrapped, err := base64.StdEncoding.DecodeString(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := enc.unwrap(keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	return openAESGCM(key, sealed, []byte(keyID))
+}
+
+// SetEncryption enables envelope encryption of outgoing message bodies with the KeyProvider
+// Receivers need a provider able to unwrap the data keys, a zero dataKeyTTL means DefaultDataKeyTTL
+func (gom *Gommunicator) SetEncryption(provider KeyProvider, dataKeyTTL time.Duration) *Gommunicator {
+	if dataKeyTTL <= 0 {
+		dataKeyTTL = DefaultDataKeyTTL
+	}
+
+	gom.encrypter = newEncrypter(provider, dataKeyTTL)
+	return gom
+}
@@ -0,0 +1,269 @@
+package gommunicator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// ErrorCatalogAction is the reserved action answering the exported error catalog
+const ErrorCatalogAction = "__errors"
+
+// ErrorCatalogEntries is the exported form of an ErrorCatalog
+// Codes and types map to their message templates per locale
+type ErrorCatalogEntries struct {
+	DefaultLocale string                       `json:"defaultLocale"`
+	Codes         map[string]map[string]string `json:"codes"`
+	Types         map[string]map[string]string `json:"types"`
+}
+
+type localizedTemplates map[string]*template.Template
+
+// ErrorCatalog holds error messages per code and locale
+// Messages are text/template templates rendered with the error context, plus .message with the original message
+type ErrorCatalog struct {
+	DefaultLocale string
+
+	lock     sync.RWMutex
+	codes    map[string]localizedTemplates
+	types    map[string]localizedTemplates
+	exported ErrorCatalogEntries
+}
+
+// defaultTypeTitles are the titles of the error types every catalog starts with
+var defaultTypeTitles = map[ErrType]map[string]string{
+	ValidationErrorType:   {"en": "Invalid data!", "pt-BR": "Dados inválidos!"},
+	NotFoundErrorType:     {"en": "Not found!", "pt-BR": "Não encontrado!"},
+	ConflictErrorType:     {"en": "Conflict!", "pt-BR": "Conflito!"},
+	UnauthorizedErrorType: {"en": "Unauthorized!", "pt-BR": "Não autorizado!"},
+	RateLimitedErrorType:  {"en": "Too many requests!", "pt-BR": "Muitas requisições!"},
+	TimeoutErrorType:      {"en": "Timed out!", "pt-BR": "Tempo esgotado!"},
+	UnavailableErrorType:  {"en": "Service unavailable!", "pt-BR": "Serviço indisponível!"},
+}
+
+// NewErrorCatalog returns a new ErrorCatalog with the titles of the library error types
+func NewErrorCatalog(defaultLocale string) *ErrorCatalog {
+	catalog := &ErrorCatalog{
+		DefaultLocale: defaultLocale,
+		codes:         make(map[string]localizedTemplates),
+		types:         make(map[string]localizedTemplates),
+		exported: ErrorCatalogEntries{
+			DefaultLocale: defaultLocale,
+			Codes:         make(map[string]map[string]string),
+			Types:         make(map[string]map[string]string),
+		},
+	}
+
+	for errType, titles := range defaultTypeTitles {
+		catalog.RegisterType(errType, titles)
+	}
+
+	return catalog
+}
+
+func parseTemplates(name string, messages map[string]string) (localizedTemplates, error) {
+	templates := make(localizedTemplates, len(messages))
+	for locale, message := range messages {
+		parsed, err := template.New(name + "/" + locale).Option("missingkey=zero").Parse(message)
+		if err != nil {
+			return nil, err
+		}
+
+		templates[normalizeLocale(locale)] = parsed
+	}
+
+	return templates, nil
+}
+
+// Register registers the messages of an error code per locale
+//
+//	catalog.Register("NOT_FOUND", map[string]string{
+//		"en":    "{{.resource}} not found",
+//		"pt-BR": "{{.resource}} não encontrado",
+//	})
+func (catalog *ErrorCatalog) Register(code string, messages map[string]string) error {
+	templates, err := parseTemplates(code, messages)
+	if err != nil {
+		return err
+	}
+
+	catalog.lock.Lock()
+	defer catalog.lock.Unlock()
+	catalog.codes[code] = templates
+	catalog.exported.Codes[code] = copyMessages(messages)
+	return nil
+}
+
+// RegisterType registers the titles of an ErrType per locale
+func (catalog *ErrorCatalog) RegisterType(errType ErrType, titles map[string]string) error {
+	templates, err := parseTemplates(string(errType), titles)
+	if err != nil {
+		return err
+	}
+
+	catalog.lock.Lock()
+	defer catalog.lock.Unlock()
+	catalog.types[string(errType)] = templates
+	catalog.exported.Types[string(errType)] = copyMessages(titles)
+	return nil
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.Replace(locale, "_", "-", -1))
+}
+
+// pick finds the template of the locale falling back to its language, then to the default locale
+func (catalog *ErrorCatalog) pick(templates localizedTemplates, locale string) *template.Template {
+	locale = normalizeLocale(locale)
+	candidates := []string{locale}
+
+	if idx := strings.Index(locale, "-"); idx > 0 {
+		candidates = append(candidates, locale[:idx])
+	}
+
+	candidates = append(candidates, normalizeLocale(catalog.DefaultLocale))
+
+	for _, candidate := range candidates {
+		if tmpl, ok := templates[candidate]; ok {
+			return tmpl
+		}
+	}
+
+	return nil
+}
+
+func (catalog *ErrorCatalog) render(templates localizedTemplates, locale string, data map[string]interface{}) (string, bool) {
+	tmpl := catalog.pick(templates, locale)
+	if tmpl == nil {
+		return "", false
+	}
+
+	var buffer bytes.Buffer
+	if err := tmpl.Execute(&buffer, data); err != nil {
+		return "", false
+	}
+
+	return buffer.String(), true
+}
+
+func renderData(err MapErr) map[string]interface{} {
+	data := make(map[string]interface{})
+	for key, value := range err.GetContext() {
+		data[key] = value
+	}
+
+	data["message"] = err.GetMessage()
+	data["code"] = err.GetCode()
+	return data
+}
+
+// Message renders the message of an error in the locale, false when the code is not registered
+func (catalog *ErrorCatalog) Message(err MapErr, locale string) (string, bool) {
+	catalog.lock.RLock()
+	templates, ok := catalog.codes[err.GetCode()]
+	catalog.lock.RUnlock()
+
+	if !ok {
+		return "", false
+	}
+
+	return catalog.render(templates, locale, renderData(err))
+}
+
+// Title renders the title of an error type in the locale, false when the type is not registered
+func (catalog *ErrorCatalog) Title(err MapErr, locale string) (string, bool) {
+	catalog.lock.RLock()
+	templates, ok := catalog.types[string(err.GetType())]
+	catalog.lock.RUnlock()
+
+	if !ok {
+		return "", false
+	}
+
+	return catalog.render(templates, locale, renderData(err))
+}
+
+// Entries exports the catalog
+func (catalog *ErrorCatalog) Entries() ErrorCatalogEntries {
+	catalog.lock.RLock()
+	defer catalog.lock.RUnlock()
+
+	entries := ErrorCatalogEntries{
+		DefaultLocale: catalog.DefaultLocale,
+		Codes:         make(map[string]map[string]string, len(catalog.exported.Codes)),
+		Types:         make(map[string]map[string]string, len(catalog.exported.Types)),
+	}
+
+	for code, messages := range catalog.exported.Codes {
+		entries.Codes[code] = copyMessages(messages)
+	}
+
+	for errType, titles := range catalog.exported.Types {
+		entries.Types[errType] = copyMessages(titles)
+	}
+
+	return entries
+}
+
+func copyMessages(messages map[string]string) map[string]string {
+	copied := make(map[string]string, len(messages))
+	for locale, message := range messages {
+		copied[locale] = message
+	}
+	return copied
+}
+
+// SetErrorCatalog sets the catalog localizing the errors answered by this service
+// The locale comes from the HeaderLocale header, the catalog is answered on ErrorCatalogAction
+func (gom *Gommunicator) SetErrorCatalog(catalog *ErrorCatalog) *Gommunicator {
+	gom.errorCatalog = catalog
+	gom.RegisterAction(ErrorCatalogAction, func(request *DataTransactionRequest) error {
+		return gom.Respond(request, catalog.Entries())
+	})
+	return gom
+}
+
+// localize renders the title and message of a failed response in the locale
+func (gom *Gommunicator) localize(response *DataTransactionResponse, mapErr MapErr, locale string) {
+	if gom.errorCatalog == nil {
+		return
+	}
+
+	if message, ok := gom.errorCatalog.Message(mapErr, locale); ok {
+		response.Message = message
+		if response.Error != nil {
+			response.Error.Message = message
+		}
+	}
+
+	if title, ok := gom.errorCatalog.Title(mapErr, locale); ok {
+		response.Title = title
+	}
+}
+
+// ErrorCatalogOf fetches the error catalog exported by a service
+func (gom *Gommunicator) ErrorCatalogOf(service string, timeout int) (*ErrorCatalogEntries, error) {
+	receiver, err := gom.Exec(&ExecInput{
+		Action:  ErrorCatalogAction,
+		Service: service,
+		Timeout: timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := <-receiver
+	if response == nil {
+		return nil, fmt.Errorf("error catalog of %s timed out", service)
+	}
+
+	if !response.Success {
+		return nil, errors.New(response.Message)
+	}
+
+	entries := new(ErrorCatalogEntries)
+	return entries, response.Decode(entries)
+}
@@ -0,0 +1,114 @@
+package gommunicator
+
+import (
+	"testing"
+	"time"
+)
+
+func testErrorCatalog(t *testing.T) *ErrorCatalog {
+	catalog := NewErrorCatalog("en")
+
+	err := catalog.Register(string(NotFound), map[string]string{
+		"en":    "{{.resource}} {{.id}} not found",
+		"pt":    "{{.resource}} {{.id}} não encontrado",
+		"pt_BR": "{{.resource}} {{.id}} não foi encontrado ({{.message}})",
+	})
+	if err != nil {
+		t.Fatalf("Register failed: %s", err.Error())
+	}
+
+	if err := catalog.RegisterType(NotFoundErrorType, map[string]string{"en": "Not found", "pt": "Não encontrado"}); err != nil {
+		t.Fatalf("RegisterType failed: %s", err.Error())
+	}
+
+	return catalog
+}
+
+func TestErrorCatalogLocales(t *testing.T) {
+	catalog := testErrorCatalog(t)
+	notFound := NewNotFoundError("order", "42", nil)
+
+	cases := map[string]string{
+		"pt-BR": "order 42 não foi encontrado (order 42 not found)", // Registered as pt_BR
+		"pt-PT": "order 42 não encontrado",                          // Falls back to the language
+		"fr":    "order 42 not found",                               // Falls back to the default locale
+		"":      "order 42 not found",
+	}
+
+	for locale, expected := range cases {
+		if message, ok := catalog.Message(notFound, locale); !ok || message != expected {
+			t.Fatalf("expected %q for locale %q, got %q", expected, locale, message)
+		}
+	}
+
+	if title, ok := catalog.Title(notFound, "pt-BR"); !ok || title != "Não encontrado" {
+		t.Fatalf("expected the pt title, got %q", title)
+	}
+
+	if _, ok := catalog.Message(NewSimpleError(Forbidden, "denied"), "en"); ok {
+		t.Fatalf("expected unregistered codes not to be rendered")
+	}
+
+	if err := catalog.Register("BROKEN", map[string]string{"en": "{{.resource"}); err == nil {
+		t.Fatalf("expected an invalid template to be rejected")
+	}
+}
+
+func TestErrorCatalogLocalize(t *testing.T) {
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false).SetErrorCatalog(testErrorCatalog(t))
+	notFound := NewNotFoundError("order", "42", nil)
+
+	response := (&DataTransaction{}).FailFromMapErr(notFound)
+	gom.localize(response, notFound, "pt")
+
+	if response.Message != "order 42 não encontrado" || response.Error.Message != response.Message || response.Title != "Não encontrado" {
+		t.Fatalf("expected the response to be localized, got %+v", response)
+	}
+
+	if response.Error.Type != NotFoundErrorType {
+		t.Fatalf("expected the error type to be kept, got %q", response.Error.Type)
+	}
+}
+
+func TestErrorCatalogEntriesCopy(t *testing.T) {
+	catalog := NewErrorCatalog("en")
+	messages := map[string]string{"en": "not found"}
+	catalog.Register(string(NotFound), messages)
+	messages["en"] = "changed by the caller"
+
+	entries := catalog.Entries()
+	entries.Codes[string(NotFound)]["en"] = "changed by a reader"
+
+	if exported := catalog.Entries().Codes[string(NotFound)]["en"]; exported != "not found" {
+		t.Fatalf("expected the exported catalog to be isolated, got %q", exported)
+	}
+}
+
+func TestErrorCatalogDefaultTitles(t *testing.T) {
+	catalog := NewErrorCatalog("en")
+	invalid := NewValidationError("invalid order", nil)
+
+	if invalid.GetType() != "validation" {
+		t.Fatalf("expected a neutral error type, got %q", invalid.GetType())
+	}
+
+	for locale, expected := range map[string]string{"pt-BR": "Dados inválidos!", "de": "Invalid data!"} {
+		if title, ok := catalog.Title(invalid, locale); !ok || title != expected {
+			t.Fatalf("expected the %s title %q, got %q", locale, expected, title)
+		}
+	}
+
+	errs := []MapErr{
+		NewNotFoundError("order", "42", nil),
+		NewConflictError("order", "42", "already paid", nil),
+		NewUnauthorizedError("expired token", nil),
+		NewRateLimitedError(time.Second, nil),
+		NewTimeoutError("stock", "stock.reserve", time.Second, nil),
+		NewUnavailableError("stock", nil),
+	}
+	for _, err := range errs {
+		if title, ok := catalog.Title(err, "en"); !ok || title == string(err.GetType()) {
+			t.Fatalf("expected a title for %s, got %q", err.GetType(), title)
+		}
+	}
+}
@@ -3,11 +3,13 @@ package gommunicator
 import (
 	"context"
 	"encoding/json"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func getRequest(action, service, dtID string, incomingService string, payload interface{}, timeout int) (*DataTransactionRequest, error) {
@@ -35,157 +37,310 @@ func getRequest(action, service, dtID string, incomingService string, payload in
 
 // ExecInput input settings for an action execution
 // Timeout is not required, if omitted default timeout will be set to 5 seconds
+// Codec is not required, if omitted the action codec or the Gommunicator codec is used
+// Headers are sent as the request metadata, use Context.Exec to propagate them from a handler
+// Context is not required, the trace context of its span is sent along the request
 type ExecInput struct {
 	DataTransactionID string
 	Action            string
 	Service           string
 	Payload           interface{}
 	Timeout           int
+	Codec             Codec
+	Headers           map[string]string
+	Context           context.Context
+
+	noResponse bool // Set by ExecTx, see DataTransactionRequest.NoResponse
 }
 
 // Exec executes an action on the services cluster
+// When a registry is configured it fails fast with ErrNoLiveInstances if the service has no live consumer
 func (gom *Gommunicator) Exec(input *ExecInput) (<-chan *DataTransactionResponse, error) {
-	// Generate request
-	request, err := getRequest(input.Action, input.Service, input.DataTransactionID, gom.ServiceName, input.Payload, input.Timeout)
-	if err != nil {
+	if err := gom.checkLiveness(input.Service); err != nil {
 		return nil, err
 	}
 
-	// Create receiver channel
-	// This is the channel that will receive a possible action's response
-	receiver := make(chan *DataTransactionResponse)
-
-	// Generate UUID to prevent duplicates
-	// This is necessary because Standard SQS may deliver duplicated messages
-	dedupUUID, err := uuid.NewRandom()
+	request, bytesMessage, err := gom.newRequest(input)
 	if err != nil {
 		return nil, err
 	}
 
-	request.DedupID = dedupUUID.String()
-
-	// Marshal request to JSON string
-	bytesMessage, err := json.Marshal(&request)
-	if err != nil {
-		return nil, err
+	// Create receiver channel
+	// This is the channel that will receive a possible action's response, or nil on timeout
+	receiver := make(chan *DataTransactionResponse, 1)
+	var once sync.Once
+	deliver := func(response *DataTransactionResponse) {
+		once.Do(func() {
+			receiver <- response
+			close(receiver)
+		})
 	}
 
-	message := string(bytesMessage)
+	parent := input.Context
+	if parent == nil {
+		parent = context.Background()
+	}
 
-	// Publish SNS message to Orchestrator Topic
-	_, err = gom.orchestrator.Publish(
-		&sns.PublishInput{
-			TopicArn: aws.String(gom.SNSTopicARN),
-			Message:  aws.String(message),
-			MessageAttributes: map[string]*sns.MessageAttributeValue{
-				"Service": {
-					StringValue: aws.String(input.Service),
-					DataType:    aws.String("String"),
-				},
-				"Action": {
-					StringValue: aws.String(input.Action),
-					DataType:    aws.String("String"),
-				},
-			},
-		},
+	spanCtx, span := gom.tracer().Start(
+		parent,
+		"exec "+request.Service+"."+request.Action,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(requestSpanAttributes(request)...),
 	)
 
-	if err != nil {
-		gom.onErr(err)
-		return nil, err
-	}
-
 	// Creates a new context related to the action req/resp
-	// This context has a timeout of expected timeout + 1 second
 	// When the context is closed, the request is timed out, by closing the listener goroutine
 	// 	and no further response to this action will be handled
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(input.Timeout)*time.Second)
-
-	go func(c context.Context, r chan *DataTransactionResponse, actionID string) {
-		for {
-			select {
-			case <-c.Done():
-				r <- nil
-				close(r)
-				deleteCallback(actionID)
-				return
-			}
-		}
-	}(ctx, receiver, *request.ActionID)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(request.Timeout)*time.Second)
+
+	sentAt := time.Now()
 
-	// Register a new response callback
+	// Register a new response callback before publishing so a fast response is not lost
 	// This is the callback that will run when a response is received
 	registerCallback(
 		*request.ActionID,
+		request.Service,
+		request.Action,
 		func(response *DataTransactionResponse) error {
-			receiver <- response
+			duration := time.Since(sentAt)
+			gom.metrics.ExecCompleted(request.Service, request.Action, duration, false)
+			span.SetAttributes(SpanSuccess.Bool(response.Success))
+			// The caller gets the response before the timeline store is written
+			deliver(response)
 			cancel()
+			gom.recordEvent(request.ID, (&TimelineEvent{
+				Type:     EventResponseReceived,
+				Peer:     request.Service,
+				Action:   request.Action,
+				ActionID: *request.ActionID,
+				Duration: duration,
+			}).responseOutcome(response))
 			return nil
 		},
 	)
 
+	gom.metrics.PendingCallbacks(pendingCallbacks())
+
+	attributes := gom.requestAttributes(request)
+	injectTraceContext(spanCtx, attributes)
+
+	// Publish SNS message to Orchestrator Topic
+	err = gom.publish(bytesMessage, attributes)
+	if err != nil {
+		cancel()
+		deleteCallback(*request.ActionID)
+		gom.metrics.PendingCallbacks(pendingCallbacks())
+		gom.metrics.PublishFailed(KindRequest, request.Action)
+		endSpan(span, err)
+		gom.tryLogErr("Data transaction request could not be sent", append(requestFields(request), errorField(err))...)
+		gom.errorHandler(err)
+		return nil, err
+	}
+
+	gom.tryLogInfo("Data transaction request sent", requestFields(request)...)
+	gom.recordEvent(request.ID, &TimelineEvent{
+		Type:     EventRequestSent,
+		Peer:     request.Service,
+		Action:   request.Action,
+		ActionID: *request.ActionID,
+	})
+	gom.tryLogDebug("Data transaction request payload", append(requestFields(request), gom.payloadField(request.Action, "", input.Payload))...)
+
+	go func(c context.Context, actionID string) {
+		<-c.Done()
+		deleteCallback(actionID)
+		gom.metrics.PendingCallbacks(pendingCallbacks())
+		if c.Err() == context.DeadlineExceeded {
+			deliver(nil)
+			gom.metrics.ExecCompleted(request.Service, request.Action, time.Since(sentAt), true)
+			gom.hooks.OnTimeout(actionID)
+			gom.recordEvent(request.ID, &TimelineEvent{
+				Type:     EventTimeout,
+				Peer:     request.Service,
+				Action:   request.Action,
+				ActionID: actionID,
+				Outcome:  OutcomeTimeout,
+				Duration: time.Since(sentAt),
+			})
+			gom.tryLogWarn("Data transaction request timed out", requestFields(request)...)
+			span.SetAttributes(SpanTimeout.Bool(true))
+			span.SetStatus(codes.Error, "timed out")
+		}
+		span.End()
+		deliver(nil)
+	}(ctx, *request.ActionID)
+
 	return receiver, nil
 }
 
-func (gom *Gommunicator) respond(message, incomingService string) error {
-	_, err := gom.orchestrator.Publish(
-		&sns.PublishInput{
-			TopicArn: aws.String(gom.SNSTopicARN),
-			Message:  aws.String(message),
-			MessageAttributes: map[string]*sns.MessageAttributeValue{
-				"Service": {
-					StringValue: aws.String(incomingService),
-					DataType:    aws.String("String"),
-				},
-			},
-		},
-	)
+// newRequest builds the request of an execution and its serialized envelope
+func (gom *Gommunicator) newRequest(input *ExecInput) (*DataTransactionRequest, []byte, error) {
+	// Generate request
+	request, err := getRequest(input.Action, input.Service, input.DataTransactionID, gom.ServiceName, input.Payload, input.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return err
+	// Generate UUID to prevent duplicates
+	// This is necessary because Standard SQS may deliver duplicated messages
+	dedupUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request.DedupID = dedupUUID.String()
+	request.Headers = copyHeaders(input.Headers)
+	request.NoResponse = input.noResponse
+
+	// Encode the payload with the selected codec
+	request.Data, request.ContentType, err = encodeData(gom.requestCodec(input), input.Payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Marshal request to JSON string, offloading the payload when it is too big to travel through SNS
+	bytesMessage, err := gom.checkIn(request.ID, request.Data, func(data interface{}, claim *ClaimCheck) ([]byte, error) {
+		request.Data, request.ClaimCheck = data, claim
+		return json.Marshal(&request)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return request, bytesMessage, nil
 }
 
-// Respond sends a response to a DataTransactionRequest
-func (gom *Gommunicator) Respond(request *DataTransactionRequest, payload interface{}) error {
-	dt := FromRequest(request)
-	dt.data = payload
+// requestAttributes returns the message attributes of a request, without trace context
+func (gom *Gommunicator) requestAttributes(request *DataTransactionRequest) map[string]*sns.MessageAttributeValue {
+	attributes := map[string]*sns.MessageAttributeValue{
+		"Service": stringAttribute(request.Service),
+		"Action":  stringAttribute(request.Action),
+	}
+	setContentTypeAttribute(attributes, request.ContentType)
+	gom.setHeaderAttributes(attributes, request.Headers)
+	return attributes
+}
 
-	response := dt.Success("")
+func setContentTypeAttribute(attributes map[string]*sns.MessageAttributeValue, contentType string) {
+	if isJSON(contentType) {
+		return
+	}
 
-	dedupUUID, err := uuid.NewRandom()
+	attributes["ContentType"] = stringAttribute(contentType)
+}
+
+// respond publishes a response to the service that sent the request
+func (gom *Gommunicator) respond(request *DataTransactionRequest, response *DataTransactionResponse) (err error) {
+	ctx, span := gom.tracer().Start(
+		request.Context(),
+		"respond "+request.Service+"."+request.Action,
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(requestSpanAttributes(request)...),
+		trace.WithAttributes(SpanSuccess.Bool(response.Success)),
+	)
+	defer func() { endSpan(span, err) }()
+
+	if request.NoResponse {
+		gom.tryLogDebug("Data transaction response dropped, the request expects none", requestFields(request)...)
+		return nil
+	}
+
+	bytesMessage, attributes, err := gom.responseMessage(request, response)
 	if err != nil {
 		return err
 	}
+	injectTraceContext(ctx, attributes)
+
+	if err = gom.publish(bytesMessage, attributes); err != nil {
+		gom.metrics.PublishFailed(KindResponse, gom.metricAction(response.Action))
+		return err
+	}
+
+	gom.auditResponse(request, response)
+	gom.recordEvent(request.ID, (&TimelineEvent{
+		Type:     EventResponseSent,
+		Peer:     request.IncomingService,
+		Action:   request.Action,
+		ActionID: actionIDValue(request.ActionID),
+	}).responseOutcome(response))
+	return nil
+}
+
+// responseMessage serializes a response and returns its message attributes, without trace context
+func (gom *Gommunicator) responseMessage(request *DataTransactionRequest, response *DataTransactionResponse) ([]byte, map[string]*sns.MessageAttributeValue, error) {
+	dedupUUID, err := uuid.NewRandom()
+	if err != nil {
+		return nil, nil, err
+	}
 
 	response.DedupID = dedupUUID.String()
 
-	bytesMessage, err := json.Marshal(&response)
+	bytesMessage, err := gom.checkIn(request.ID, response.Data, func(data interface{}, claim *ClaimCheck) ([]byte, error) {
+		response.Data, response.ClaimCheck = data, claim
+		return json.Marshal(&response)
+	})
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	message := string(bytesMessage)
+	attributes := map[string]*sns.MessageAttributeValue{
+		"Service": stringAttribute(request.IncomingService),
+	}
+	setContentTypeAttribute(attributes, response.ContentType)
+	gom.setHeaderAttributes(attributes, response.Headers)
 
-	return gom.respond(message, request.IncomingService)
+	return bytesMessage, attributes, nil
 }
 
-// RespondError sends a response to a DataTransactionRequest
-func (gom *Gommunicator) RespondError(request *DataTransactionRequest, mapErr MapErr) error {
-	dt := FromRequest(request)
-	response := dt.FailFromMapErr(mapErr)
+// Respond sends a response to a DataTransactionRequest
+// The request headers are sent back on the response
+func (gom *Gommunicator) Respond(request *DataTransactionRequest, payload interface{}) error {
+	return gom.respondWithHeaders(request, payload, request.Headers)
+}
 
-	dedupUUID, err := uuid.NewRandom()
+func (gom *Gommunicator) respondWithHeaders(request *DataTransactionRequest, payload interface{}, headers map[string]string) error {
+	response, err := gom.successResponse(request, payload, headers)
 	if err != nil {
 		return err
 	}
 
-	response.DedupID = dedupUUID.String()
+	return gom.respond(request, response)
+}
 
-	bytesMessage, err := json.Marshal(&response)
+// successResponse builds the response of a handled request
+func (gom *Gommunicator) successResponse(request *DataTransactionRequest, payload interface{}, headers map[string]string) (*DataTransactionResponse, error) {
+	gom.validateResponse(request, payload)
+
+	data, contentType, err := encodeData(gom.responseCodec(request), payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	message := string(bytesMessage)
+	dt := FromRequest(request)
+	dt.data = data
+
+	response := dt.Success("")
+	response.ContentType = contentType
+	response.Headers = copyHeaders(headers)
 
-	return gom.respond(message, request.IncomingService)
+	return response, nil
+}
+
+// RespondError sends a response to a DataTransactionRequest
+// The request headers are sent back on the response, the error is localized with the error catalog
+func (gom *Gommunicator) RespondError(request *DataTransactionRequest, mapErr MapErr) error {
+	return gom.respondErrorWithHeaders(request, mapErr, request.Headers)
+}
+
+func (gom *Gommunicator) respondErrorWithHeaders(request *DataTransactionRequest, mapErr MapErr, headers map[string]string) error {
+	return gom.respond(request, gom.errorResponse(request, mapErr, headers))
+}
+
+// errorResponse builds the localized response of a failed request
+func (gom *Gommunicator) errorResponse(request *DataTransactionRequest, mapErr MapErr, headers map[string]string) *DataTransactionResponse {
+	dt := FromRequest(request)
+	response := dt.FailFromMapErr(mapErr)
+	response.Headers = copyHeaders(headers)
+	gom.localize(response, mapErr, headers[HeaderLocale])
+	return response
 }
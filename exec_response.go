@@ -2,29 +2,78 @@ package gommunicator
 
 import (
 	"errors"
+	"fmt"
+	"sync"
 )
 
+// ErrCallbackNotFound is returned when a response has no pending Exec, it timed out or was already answered
+var ErrCallbackNotFound = errors.New("callback not found")
+
 type responseCallback func(*DataTransactionResponse) error
 
-var callbacks map[string]responseCallback = make(map[string]responseCallback)
+// pendingCallback is the callback of an Exec waiting for the response of service to action
+type pendingCallback struct {
+	service  string
+	action   string
+	callback responseCallback
+}
+
+var callbacks map[string]*pendingCallback = make(map[string]*pendingCallback)
+var callbacksLock sync.Mutex
 
-func registerCallback(actionID string, callback responseCallback) {
-	callbacks[actionID] = callback
+func registerCallback(actionID, service, action string, callback responseCallback) {
+	callbacksLock.Lock()
+	defer callbacksLock.Unlock()
+	callbacks[actionID] = &pendingCallback{service: service, action: action, callback: callback}
+}
+
+// pendingExec returns the called service and action of the Exec waiting for a response
+func pendingExec(actionID *string) (service, action string, ok bool) {
+	if actionID == nil {
+		return "", "", false
+	}
+
+	callbacksLock.Lock()
+	defer callbacksLock.Unlock()
+	if pending, ok := callbacks[*actionID]; ok {
+		return pending.service, pending.action, true
+	}
+
+	return "", "", false
 }
 
 func deleteCallback(actionID string) {
+	callbacksLock.Lock()
+	defer callbacksLock.Unlock()
 	if _, ok := callbacks[actionID]; ok == true {
 		delete(callbacks, actionID)
 	}
 }
 
-func callCallback(response *DataTransactionResponse) error {
+// callCallback calls the callback waiting for a response
+// When messages are signed, sender is the authenticated sender and must be the service the request was sent to,
+// a response from another service is rejected and the callback keeps waiting
+func callCallback(response *DataTransactionResponse, sender string) error {
 	if response.ActionID != nil {
-		if callback, ok := callbacks[*response.ActionID]; ok == true {
-			delete(callbacks, *response.ActionID)
-			return callback(response)
+		callbacksLock.Lock()
+		pending, ok := callbacks[*response.ActionID]
+		if ok && sender != "" && sender != pending.service {
+			callbacksLock.Unlock()
+			return fmt.Errorf("%w: %s answered a request sent to %s", ErrInvalidSignature, sender, pending.service)
+		}
+		delete(callbacks, *response.ActionID)
+		callbacksLock.Unlock()
+
+		if ok == true {
+			return pending.callback(response)
 		}
 	}
 
-	return errors.New("callback not found")
+	return ErrCallbackNotFound
+}
+
+func pendingCallbacks() int {
+	callbacksLock.Lock()
+	defer callbacksLock.Unlock()
+	return len(callbacks)
 }
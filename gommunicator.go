@@ -1,12 +1,16 @@
 package gommunicator
 
 import (
-	"fmt"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Gommunicator is the main wrapper for connecting to the services group
@@ -21,17 +25,58 @@ type Gommunicator struct {
 	DynamoTable string
 
 	errorHandler func(error)
-	mq           *sqs.SQS
-	orchestrator *sns.SNS
-	dynamo       *dynamodb.DynamoDB
-	actions      map[string]ActionHandler
-	log          bool
-	logger       *Logger
+	mq           sqsiface.SQSAPI
+	orchestrator snsiface.SNSAPI
+	dynamo       dynamodbiface.DynamoDBAPI
+	actions      *router
+	schemas      *schemaStore
+	validators   *validatorStore
+	devMode      bool
+	codec        Codec
+	actionCodecs map[string]Codec
+	codecsLock   sync.RWMutex
+
+	compressor           Compressor
+	compressionThreshold int
+
+	blobStore           BlobStore
+	claimCheckThreshold int
+	claimCheckRetention time.Duration
+
+	encrypter *encrypter
+
+	signingKey      SigningKey
+	keyRing         *KeyRing
+	signatureMaxAge time.Duration
+
+	authorizer       authorizer
+	attributeHeaders []string
+	errorCatalog     *ErrorCatalog
+	log              bool
+	logger           Logger
+	tracerProvider   trace.TracerProvider
+	metrics          Metrics
+	hooks            *hookList
+	auditor          *auditor
+	redactor         *redactor
+	sagas            *sagaRegistry
+	workflows        *workflowRegistry
+	timeline         TimelineStore
+	outbox           *Outbox
+
+	instanceID        string
+	startedAt         time.Time
+	registry          Registry
+	live              *liveCache
+	heartbeatInterval time.Duration
+	stop              chan struct{}
+	stopOnce          sync.Once
 }
 
 // NewGommunicator returns a new Gommunicator using the SQS as mq using the provided AWS IAM Account ID and secret
-func NewGommunicator(sqs *sqs.SQS, sns *sns.SNS, dynamo *dynamodb.DynamoDB, serviceQueueURL, serviceName, dynamoTable, snsTopicArn string) *Gommunicator {
-	return &Gommunicator{
+// The AWS clients are taken by their SDK interfaces, so they can be wrapped or faked
+func NewGommunicator(sqs sqsiface.SQSAPI, sns snsiface.SNSAPI, dynamo dynamodbiface.DynamoDBAPI, serviceQueueURL, serviceName, dynamoTable, snsTopicArn string) *Gommunicator {
+	gom := &Gommunicator{
 		ServiceName:     serviceName,
 		ServiceQueueURL: serviceQueueURL,
 		SNSTopicARN:     snsTopicArn,
@@ -41,30 +86,62 @@ func NewGommunicator(sqs *sqs.SQS, sns *sns.SNS, dynamo *dynamodb.DynamoDB, serv
 		orchestrator: sns,
 		errorHandler: func(err error) {},
 		dynamo:       dynamo,
-		actions:      make(map[string]ActionHandler),
+		actions:      newRouter(),
+		schemas:      newSchemaStore(),
+		validators:   newValidatorStore(),
+		codec:        JSONCodec,
+		actionCodecs: make(map[string]Codec),
 		log:          true,
-		logger:       getLogger(),
+		logger:       defaultLogger(),
+		metrics:      noopMetrics{},
+		hooks:        new(hookList),
+		redactor:     newRedactor(),
+		sagas:        newSagaRegistry(),
+		workflows:    newWorkflowRegistry(),
+
+		attributeHeaders: defaultAttributeHeaders,
+		instanceID:       uuid.New().String(),
+		live:             newLiveCache(),
+		stop:             make(chan struct{}),
+	}
+
+	gom.RegisterAction(DescribeAction, gom.describeHandler)
+
+	return gom
+}
+
+func (gom *Gommunicator) tryLogDebug(message string, fields ...Field) {
+	if gom.log {
+		gom.logger.Debug(message, fields...)
+	}
+}
+
+func (gom *Gommunicator) tryLogInfo(message string, fields ...Field) {
+	if gom.log {
+		gom.logger.Info(message, fields...)
 	}
 }
 
-func (gom *Gommunicator) tryLogInfo(values ...interface{}) {
+func (gom *Gommunicator) tryLogWarn(message string, fields ...Field) {
 	if gom.log {
-		gom.logger.log(true, values...)
+		gom.logger.Warn(message, fields...)
 	}
 }
 
-func (gom *Gommunicator) tryLogErr(values ...interface{}) {
+func (gom *Gommunicator) tryLogErr(message string, fields ...Field) {
 	if gom.log {
-		gom.logger.log(false, values...)
+		gom.logger.Error(message, fields...)
 	}
 }
 
 func (gom *Gommunicator) onErr(err error) {
-	gom.tryLogErr(err)
-	gom.onErr(err)
+	gom.tryLogErr(err.Error())
+	if gom.errorHandler != nil {
+		gom.errorHandler(err)
+	}
 }
 
-// SetLogState sets the log state
+// SetLogState enables or disables logging, see SetLogger
 func (gom *Gommunicator) SetLogState(state bool) *Gommunicator {
 	gom.log = state
 	return gom
@@ -78,15 +155,24 @@ func (gom *Gommunicator) SetErrorHandler(errorHandle func(error)) *Gommunicator
 
 // Start start listening to new messages sended to this service's queue URL
 func (gom *Gommunicator) Start(maxMessage int64, longPollingTime int64) error {
-	// TODO: check for possibility of start
-	if false {
-		return nil
+	if err := gom.startRegistry(); err != nil {
+		return err
 	}
 
+	gom.startBlobSweeper()
+	gom.startOutboxRelay()
+
 	gom.tryLogInfo("Gommunicator is running!")
-	gom.tryLogInfo(fmt.Sprintf("%s service is waiting for messages...", gom.ServiceName))
+	gom.tryLogInfo("Service is waiting for messages...", F(FieldService, gom.ServiceName))
 
 	for {
+		select {
+		case <-gom.stop:
+			return nil
+		default:
+		}
+
+		polledAt := time.Now()
 		messageOutput, err := gom.mq.ReceiveMessage(&sqs.ReceiveMessageInput{
 			QueueUrl:            &gom.ServiceQueueURL,
 			AttributeNames:      aws.StringSlice([]string{"All"}),
@@ -94,11 +180,13 @@ func (gom *Gommunicator) Start(maxMessage int64, longPollingTime int64) error {
 			MaxNumberOfMessages: aws.Int64(maxMessage),
 		})
 
-		if gom.errorHandler != nil && err != nil {
+		if err != nil {
 			gom.onErr(err)
 			continue
 		}
 
+		gom.metrics.QueuePolled(time.Since(polledAt), len(messageOutput.Messages))
+
 		if len(messageOutput.Messages) > 0 {
 			for _, message := range messageOutput.Messages {
 				go func(m *sqs.Message) {
@@ -114,3 +202,10 @@ func (gom *Gommunicator) Start(maxMessage int64, longPollingTime int64) error {
 		}
 	}
 }
+
+// Stop stops listening to new messages after the current poll and deregisters this instance
+func (gom *Gommunicator) Stop() {
+	gom.stopOnce.Do(func() {
+		close(gom.stop)
+	})
+}
@@ -0,0 +1,174 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// fakeCluster delivers published messages straight to the Gommunicator of the target service
+// It stands for SNS, the SQS queues and the dedup table of a cluster
+type fakeCluster struct {
+	lock     sync.Mutex
+	services map[string]*Gommunicator
+	// failPublish, when set, fails the publication of the messages it returns an error for
+	failPublish func(attributes map[string]string) error
+	published   []map[string]string
+
+	dynamo *fakeDynamo
+}
+
+func newFakeCluster() *fakeCluster {
+	return &fakeCluster{
+		services: make(map[string]*Gommunicator),
+		dynamo:   &fakeDynamo{items: make(map[string]map[string]*dynamodb.AttributeValue)},
+	}
+}
+
+// service returns a Gommunicator of the cluster, without logs
+func (cluster *fakeCluster) service(name string) *Gommunicator {
+	gom := NewGommunicator(fakeSQS{}, &fakeSNS{cluster: cluster}, cluster.dynamo, "", name, "dedup", "").SetLogState(false)
+
+	cluster.lock.Lock()
+	cluster.services[name] = gom
+	cluster.lock.Unlock()
+
+	return gom
+}
+
+func (cluster *fakeCluster) publications() []map[string]string {
+	cluster.lock.Lock()
+	defer cluster.lock.Unlock()
+	return append([]map[string]string{}, cluster.published...)
+}
+
+type fakeSNS struct {
+	snsiface.SNSAPI
+	cluster *fakeCluster
+}
+
+func (fake *fakeSNS) Publish(input *sns.PublishInput) (*sns.PublishOutput, error) {
+	attributes := attributeValues(input.MessageAttributes)
+	if len(input.MessageAttributes) > maxMessageAttributes {
+		return nil, errors.New("InvalidParameterValue: too many message attributes")
+	}
+
+	fake.cluster.lock.Lock()
+	failPublish := fake.cluster.failPublish
+	target := fake.cluster.services[attributes["Service"]]
+	fake.cluster.lock.Unlock()
+
+	if failPublish != nil {
+		if err := failPublish(attributes); err != nil {
+			return nil, err
+		}
+	}
+
+	fake.cluster.lock.Lock()
+	fake.cluster.published = append(fake.cluster.published, attributes)
+	fake.cluster.lock.Unlock()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"Message":           aws.StringValue(input.Message),
+		"MessageAttributes": notificationAttributes(input.MessageAttributes),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if target != nil {
+		go target.handleMessage(&sqs.Message{Body: aws.String(string(body))})
+	}
+
+	return &sns.PublishOutput{MessageId: aws.String("fake")}, nil
+}
+
+type fakeSQS struct {
+	sqsiface.SQSAPI
+}
+
+func (fakeSQS) DeleteMessage(*sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+// fakeDynamo implements the dedup table operations
+type fakeDynamo struct {
+	dynamodbiface.DynamoDBAPI
+	lock  sync.Mutex
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (fake *fakeDynamo) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	return &dynamodb.GetItemOutput{Item: fake.items[aws.StringValue(input.Key["id"].S)]}, nil
+}
+
+func (fake *fakeDynamo) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	id := aws.StringValue(input.Item["id"].S)
+	if _, ok := fake.items[id]; ok && aws.StringValue(input.ConditionExpression) == "attribute_not_exists(id)" {
+		return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "exists", nil)
+	}
+
+	fake.items[id] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (fake *fakeDynamo) UpdateItem(input *dynamodb.UpdateItemInput) (*dynamodb.UpdateItemOutput, error) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	if item, ok := fake.items[aws.StringValue(input.Key["id"].S)]; ok && input.ExpressionAttributeValues[":st"] != nil {
+		item["status"] = input.ExpressionAttributeValues[":st"]
+	}
+
+	return &dynamodb.UpdateItemOutput{}, nil
+}
+
+// jsonTextCodec encodes data as JSON but travels as codec bytes, standing for msgpack or cbor
+type jsonTextCodec struct{}
+
+func (jsonTextCodec) ContentType() string {
+	return "application/x-json-text"
+}
+
+func (jsonTextCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonTextCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func TestFakeCluster(t *testing.T) {
+	cluster := newFakeCluster()
+	orders := cluster.service("orders")
+	stock := cluster.service("stock")
+
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, map[string]int{"reserved": 2})
+	})
+
+	receiver, err := orders.Exec(&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve", Payload: map[string]int{"quantity": 2}})
+	if err != nil {
+		t.Fatalf("Exec failed: %s", err.Error())
+	}
+
+	response := <-receiver
+	if response == nil || !response.Success {
+		t.Fatalf("expected a successful response, got %+v", response)
+	}
+}
@@ -1,47 +1,41 @@
 package gommunicator
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 	"github.com/aws/aws-sdk-go/service/sqs"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func handlerErr(id, action, incoming, service string) string {
-	return formatWithID(
-		id,
-		"data transaction id",
-		fmt.Sprintf("Data transaction request errored on %s.%s from %s", action, service, incoming),
-	)
-}
-
-func handlerRequestSuccess(id, action, incoming, service string) string {
-	return formatWithID(
-		id,
-		"data transaction id",
-		fmt.Sprintf("Data transaction request received on %s.%s from %s", action, service, incoming),
-	)
-}
-
-func handlerSuccessResponse(id, action string) string {
-	return formatWithID(
-		id,
-		"data transaction id",
-		fmt.Sprintf("Data transaction success internal response read from action %s", action),
-	)
-}
-
-func (gom *Gommunicator) handleDuplicated(dupID string) (*dtDocument, error) {
+// handleDuplicated returns the stored state of a message already handled or being handled
+// Otherwise the message state is created, so concurrent deliveries of it are seen as duplicated
+// fields identify the message on the logs
+func (gom *Gommunicator) handleDuplicated(dupID string, fields []Field) (*dtDocument, error) {
 	// check for dynamodb request state
 	dt, err := gom.checkDT(dupID)
+	if err != nil || dt != nil {
+		return dt, err
+	}
 
-	if err == nil && dt == nil {
-		gom.createDT(dupID)
+	if err := gom.createDT(dupID); err != nil {
+		var awsErr awserr.Error
+		if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+			// Another consumer created it meanwhile
+			return &dtDocument{ID: dupID, Status: inProgress}, nil
+		}
+
+		gom.tryLogErr("Data transaction state could not be created", append(fields, F("dedup_id", dupID), errorField(err))...)
 	}
 
-	return dt, err
+	return nil, nil
 }
 
 func (gom *Gommunicator) deleteMessage(message *sqs.Message) error {
@@ -52,7 +46,36 @@ func (gom *Gommunicator) deleteMessage(message *sqs.Message) error {
 	return err
 }
 
+// messageAttribute reads a string message attribute from a SNS notification
+func messageAttribute(attributes map[string]interface{}, name string) string {
+	attribute, _ := attributes[name].(map[string]interface{})
+	value, _ := attribute["Value"].(string)
+	return value
+}
+
+// dispatch authorizes and validates a request before calling its action
+// Denied and invalid requests are answered with the error instead
+func (gom *Gommunicator) dispatch(request *DataTransactionRequest) error {
+	gom.hooks.OnDispatch(request)
+
+	if denial := gom.authorize(request); denial != nil {
+		gom.tryLogWarn("Data transaction request denied", append(requestFields(request), F("incoming_service", request.IncomingService))...)
+		gom.auditRequest(request, denial)
+		return gom.RespondError(request, denial)
+	}
+
+	if invalid := gom.validateRequest(request); invalid != nil {
+		gom.auditRequest(request, invalid)
+		return gom.RespondError(request, invalid)
+	}
+
+	err := gom.CallAction(request)
+	gom.auditRequest(request, err)
+	return err
+}
+
 func (gom *Gommunicator) handleMessage(message *sqs.Message) error {
+	gom.hooks.OnReceive(message)
 	gom.deleteMessage(message)
 
 	var raw map[string]interface{}
@@ -63,60 +86,169 @@ func (gom *Gommunicator) handleMessage(message *sqs.Message) error {
 	}
 
 	rawMessage, hasMsg := raw["Message"].(string)
-	_, isRequest := raw["MessageAttributes"].(map[string]interface{})["Action"]
+	attributes, _ := raw["MessageAttributes"].(map[string]interface{})
 
 	if !hasMsg {
 		return errors.New("empty message")
 	}
 
+	if err := unpackAttributes(attributes); err != nil {
+		return err
+	}
+
+	_, isRequest := attributes["Action"]
+	contentType := messageAttribute(attributes, "ContentType")
+
+	// Reject unsigned or tampered messages before reading them
+	sender, err := gom.verify(rawMessage, attributes)
+	if err != nil {
+		return err
+	}
+
+	body, err := gom.decodeBody(rawMessage, attributes)
+	if err != nil {
+		return err
+	}
+
 	request := new(DataTransactionRequest)
 	response := new(DataTransactionResponse)
 
-	var errDyn error
 	var dedupID string
 
 	if isRequest {
-		err := json.Unmarshal([]byte(rawMessage), request)
+		err := json.Unmarshal(body, request)
 		if err != nil {
 			return err
 		}
+		if sender != "" && request.IncomingService != sender {
+			return fmt.Errorf("%w: %s sent a request as %s", ErrInvalidSignature, sender, request.IncomingService)
+		}
+		if request.ContentType == "" {
+			request.ContentType = contentType
+		}
+		if request.ClaimCheck != nil {
+			if request.Data, err = gom.checkOut(request.ClaimCheck, request.ContentType); err != nil {
+				return err
+			}
+		}
 		dedupID = request.DedupID
 	} else {
-		err := json.Unmarshal([]byte(rawMessage), response)
+		err := json.Unmarshal(body, response)
 		if err != nil {
 			return err
 		}
+		if response.ContentType == "" {
+			response.ContentType = contentType
+		}
+		if response.ClaimCheck != nil {
+			if response.Data, err = gom.checkOut(response.ClaimCheck, response.ContentType); err != nil {
+				return err
+			}
+		}
 		dedupID = response.DedupID
 	}
 
-	_, errDyn = gom.handleDuplicated(dedupID)
+	// Continue the trace of the sender
+	var span trace.Span
+	if isRequest {
+		var ctx context.Context
+		ctx, span = gom.tracer().Start(
+			extractTraceContext(attributes),
+			"handle "+request.Service+"."+request.Action,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(requestSpanAttributes(request)...),
+		)
+		request.WithContext(ctx)
+	} else {
+		_, span = gom.tracer().Start(
+			extractTraceContext(attributes),
+			"response "+response.Action,
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(responseSpanAttributes(response)...),
+		)
+	}
+
+	kind, action, label := KindResponse, response.Action, responseMetricAction(response)
+	responder := sender
+	if responder == "" {
+		// Unsigned responses don't tell their sender, it is the service the Exec called
+		responder, _, _ = pendingExec(response.ActionID)
+	}
+	fields := responseFields(response, responder)
+	if isRequest {
+		kind, action, label = KindRequest, request.Action, gom.metricAction(request.Action)
+		fields = append(requestFields(request), F("incoming_service", request.IncomingService))
+	}
+	gom.metrics.MessageReceived(kind, label)
+
+	duplicated, errDyn := gom.handleDuplicated(dedupID, fields)
+	span.SetAttributes(SpanDuplicate.Bool(duplicated != nil))
+	if duplicated != nil {
+		// Standard SQS delivers at least once, the message is being or was already handled
+		gom.metrics.DuplicateMessage(kind, label)
+		gom.hooks.OnDuplicate(dedupID, string(duplicated.Status))
+		dtID, actionID := response.ID, response.ActionID
+		if isRequest {
+			dtID, actionID = request.ID, request.ActionID
+		}
+		gom.recordEvent(dtID, &TimelineEvent{Type: EventDuplicateIgnored, Action: action, ActionID: actionIDValue(actionID)})
+		gom.tryLogInfo("Duplicated message ignored", append(fields, F("dedup_id", dedupID), F("status", duplicated.Status))...)
+		span.End()
+		return nil
+	}
+
+	startedAt := time.Now()
 
 	if errDyn == nil {
 		if isRequest {
-			gom.tryLogInfo(handlerRequestSuccess(request.ID, request.Action, request.IncomingService, request.Service))
-			err := gom.CallAction(request)
+			gom.tryLogInfo("Data transaction request received", fields...)
+			gom.tryLogDebug("Data transaction request payload", append(fields, gom.payloadField(request.Action, request.ContentType, request.Data))...)
+			err := gom.dispatch(request)
+			gom.metrics.MessageProcessed(kind, label, err == nil, time.Since(startedAt))
+			gom.hooks.OnHandled(request, err, time.Since(startedAt))
+			gom.recordEvent(request.ID, (&TimelineEvent{
+				Time:     startedAt.UTC(),
+				Type:     EventRequestHandled,
+				Peer:     request.IncomingService,
+				Action:   request.Action,
+				ActionID: actionIDValue(request.ActionID),
+				Duration: time.Since(startedAt),
+			}).errorOutcome(err))
 
 			if err != nil {
-				gom.tryLogErr(handlerErr(request.ID, request.Action, request.IncomingService, request.Service))
+				gom.tryLogErr("Data transaction request errored", append(fields, errorField(err))...)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
 				gom.updateDT(dedupID, errored)
 			} else {
 				gom.updateDT(dedupID, completed)
 			}
 		} else {
-			gom.tryLogInfo(handlerSuccessResponse(response.ID, response.Action))
-			err := callCallback(response)
+			gom.tryLogInfo("Data transaction response received", fields...)
+			gom.tryLogDebug("Data transaction response payload", append(fields, gom.payloadField(response.Action, response.ContentType, response.Data))...)
+			gom.hooks.OnResponse(response)
+			err := callCallback(response, sender)
+			gom.metrics.MessageProcessed(kind, label, err == nil, time.Since(startedAt))
+			if errors.Is(err, ErrCallbackNotFound) {
+				gom.metrics.CallbackNotFound(label)
+			}
 
 			if err != nil {
-				gom.tryLogErr(handlerErr(request.ID, request.Action, request.IncomingService, request.Service))
-				gom.updateDT(request.DedupID, errored)
+				gom.tryLogErr("Data transaction response errored", append(fields, errorField(err))...)
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				gom.updateDT(dedupID, errored)
 			} else {
-				gom.updateDT(request.DedupID, completed)
+				gom.updateDT(dedupID, completed)
 			}
 		}
 	} else {
 		gom.updateDT(dedupID, errored)
-		gom.tryLogErr(errDyn)
+		gom.tryLogErr("Data transaction deduplication failed", append(fields, F("dedup_id", dedupID), errorField(errDyn))...)
+		span.RecordError(errDyn)
+		span.SetStatus(codes.Error, errDyn.Error())
 	}
 
+	span.End()
 	return nil
 }
@@ -0,0 +1,115 @@
+package gommunicator
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// PublishedMessage is a message published to the SNS topic
+type PublishedMessage struct {
+	Body       []byte            // Serialized request or response, before compression and encryption
+	Message    string            // Message sent to SNS
+	Attributes map[string]string // Message attributes
+	Err        error             // Publish error, nil when published
+}
+
+// Hooks observes the runtime of a Gommunicator
+// Hooks are called synchronously, they must be quick and must not modify their arguments
+// Embed NoopHooks to implement only some of them
+type Hooks interface {
+	// OnReceive is called for every message read from the queue
+	OnReceive(raw *sqs.Message)
+	// OnDuplicate is called for messages already handled or being handled, status is the stored status
+	OnDuplicate(dedupID string, status string)
+	// OnDispatch is called before a request is authorized, validated and handled
+	OnDispatch(request *DataTransactionRequest)
+	// OnHandled is called after a request was handled
+	OnHandled(request *DataTransactionRequest, err error, duration time.Duration)
+	// OnPublish is called after a message was published, or failed to
+	OnPublish(message *PublishedMessage)
+	// OnResponse is called for every response received, before its Exec is answered
+	OnResponse(response *DataTransactionResponse)
+	// OnTimeout is called when an Exec got no response before its timeout
+	OnTimeout(actionID string)
+}
+
+// NoopHooks implements Hooks doing nothing
+type NoopHooks struct{}
+
+// OnReceive does nothing
+func (NoopHooks) OnReceive(raw *sqs.Message) {}
+
+// OnDuplicate does nothing
+func (NoopHooks) OnDuplicate(dedupID string, status string) {}
+
+// OnDispatch does nothing
+func (NoopHooks) OnDispatch(request *DataTransactionRequest) {}
+
+// OnHandled does nothing
+func (NoopHooks) OnHandled(request *DataTransactionRequest, err error, duration time.Duration) {}
+
+// OnPublish does nothing
+func (NoopHooks) OnPublish(message *PublishedMessage) {}
+
+// OnResponse does nothing
+func (NoopHooks) OnResponse(response *DataTransactionResponse) {}
+
+// OnTimeout does nothing
+func (NoopHooks) OnTimeout(actionID string) {}
+
+// hookList calls every hook added to a Gommunicator
+type hookList struct {
+	lock  sync.RWMutex
+	hooks []Hooks
+}
+
+func (list *hookList) add(hooks Hooks) {
+	list.lock.Lock()
+	defer list.lock.Unlock()
+	list.hooks = append(list.hooks, hooks)
+}
+
+func (list *hookList) each(call func(Hooks)) {
+	list.lock.RLock()
+	defer list.lock.RUnlock()
+
+	for _, hooks := range list.hooks {
+		call(hooks)
+	}
+}
+
+func (list *hookList) OnReceive(raw *sqs.Message) {
+	list.each(func(hooks Hooks) { hooks.OnReceive(raw) })
+}
+
+func (list *hookList) OnDuplicate(dedupID string, status string) {
+	list.each(func(hooks Hooks) { hooks.OnDuplicate(dedupID, status) })
+}
+
+func (list *hookList) OnDispatch(request *DataTransactionRequest) {
+	list.each(func(hooks Hooks) { hooks.OnDispatch(request) })
+}
+
+func (list *hookList) OnHandled(request *DataTransactionRequest, err error, duration time.Duration) {
+	list.each(func(hooks Hooks) { hooks.OnHandled(request, err, duration) })
+}
+
+func (list *hookList) OnPublish(message *PublishedMessage) {
+	list.each(func(hooks Hooks) { hooks.OnPublish(message) })
+}
+
+func (list *hookList) OnResponse(response *DataTransactionResponse) {
+	list.each(func(hooks Hooks) { hooks.OnResponse(response) })
+}
+
+func (list *hookList) OnTimeout(actionID string) {
+	list.each(func(hooks Hooks) { hooks.OnTimeout(actionID) })
+}
+
+// AddHooks adds hooks observing this Gommunicator, hooks are called in the order they were added
+func (gom *Gommunicator) AddHooks(hooks Hooks) *Gommunicator {
+	gom.hooks.add(hooks)
+	return gom
+}
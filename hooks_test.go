@@ -0,0 +1,154 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+type recordingHooks struct {
+	NoopHooks
+	lock       sync.Mutex
+	dispatched []string
+}
+
+func (hooks *recordingHooks) OnDispatch(request *DataTransactionRequest) {
+	hooks.lock.Lock()
+	defer hooks.lock.Unlock()
+	hooks.dispatched = append(hooks.dispatched, request.Action)
+}
+
+func (hooks *recordingHooks) actions() []string {
+	hooks.lock.Lock()
+	defer hooks.lock.Unlock()
+	return append([]string{}, hooks.dispatched...)
+}
+
+func TestHooksOnDispatch(t *testing.T) {
+	first, second := new(recordingHooks), new(recordingHooks)
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false).AddHooks(first).AddHooks(second)
+
+	handled := false
+	gom.RegisterAction("orders.create", func(request *DataTransactionRequest) error {
+		handled = true
+		return nil
+	})
+
+	if err := gom.dispatch(&DataTransactionRequest{Action: "orders.create", Timeout: 5}); err != nil {
+		t.Fatalf("dispatch failed: %s", err.Error())
+	}
+
+	if !handled || len(first.dispatched) != 1 || len(second.dispatched) != 1 || first.dispatched[0] != "orders.create" {
+		t.Fatalf("expected every hook to observe the dispatch, got %v and %v", first.dispatched, second.dispatched)
+	}
+}
+
+// eventHooks records the name of every hook called
+type eventHooks struct {
+	lock   sync.Mutex
+	called []string
+}
+
+func (hooks *eventHooks) record(event string) {
+	hooks.lock.Lock()
+	defer hooks.lock.Unlock()
+	hooks.called = append(hooks.called, event)
+}
+
+func (hooks *eventHooks) OnReceive(raw *sqs.Message) {
+	hooks.record("receive")
+}
+
+func (hooks *eventHooks) OnDuplicate(dedupID string, status string) {
+	hooks.record("duplicate")
+}
+
+func (hooks *eventHooks) OnDispatch(request *DataTransactionRequest) {
+	hooks.record("dispatch")
+}
+
+func (hooks *eventHooks) OnHandled(request *DataTransactionRequest, err error, duration time.Duration) {
+	hooks.record("handled")
+}
+
+func (hooks *eventHooks) OnPublish(message *PublishedMessage) {
+	hooks.record("publish")
+}
+
+func (hooks *eventHooks) OnResponse(response *DataTransactionResponse) {
+	hooks.record("response")
+}
+
+func (hooks *eventHooks) OnTimeout(actionID string) {
+	hooks.record("timeout")
+}
+
+// waitFor waits for the hooks to have been called as expected
+func (hooks *eventHooks) waitFor(t *testing.T, expected string) {
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		hooks.lock.Lock()
+		called := strings.Join(hooks.called, ",")
+		hooks.lock.Unlock()
+
+		if called == expected {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the hooks %s, got %s", expected, called)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHooksLifecycle(t *testing.T) {
+	cluster := newFakeCluster()
+	caller, callee := new(eventHooks), new(eventHooks)
+	orders := cluster.service("orders").AddHooks(caller)
+	stock := cluster.service("stock").AddHooks(callee)
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, "reserved")
+	})
+
+	request, envelope, err := orders.newRequest(&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve", Timeout: 2})
+	if err != nil {
+		t.Fatalf("newRequest failed: %s", err.Error())
+	}
+
+	receiver := make(chan *DataTransactionResponse, 1)
+	registerCallback(*request.ActionID, "stock", "stock.reserve", func(response *DataTransactionResponse) error {
+		receiver <- response
+		return nil
+	})
+
+	if err := orders.publish(envelope, orders.requestAttributes(request)); err != nil {
+		t.Fatalf("publish failed: %s", err.Error())
+	}
+
+	if response := <-receiver; response == nil || !response.Success {
+		t.Fatalf("expected a successful response, got %+v", response)
+	}
+
+	caller.waitFor(t, "publish,receive,response")
+	callee.waitFor(t, "receive,dispatch,publish,handled")
+
+	// A redelivery of the request is only seen as duplicated
+	body, _ := json.Marshal(map[string]interface{}{
+		"Message":           string(envelope),
+		"MessageAttributes": notificationAttributes(orders.requestAttributes(request)),
+	})
+	stock.handleMessage(&sqs.Message{Body: aws.String(string(body))})
+	callee.waitFor(t, "receive,dispatch,publish,handled,receive,duplicate")
+
+	// Nobody answers the missing service
+	if _, err := orders.Exec(&ExecInput{DataTransactionID: "dt-1", Service: "missing", Action: "missing.call", Timeout: 1}); err != nil {
+		t.Fatalf("Exec failed: %s", err.Error())
+	}
+	caller.waitFor(t, "publish,receive,response,publish,timeout")
+}
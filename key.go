@@ -95,7 +95,10 @@ func (gom *Gommunicator) updateDT(dtID string, status dtStatus) error {
 				S: aws.String(dtID),
 			},
 		},
-		UpdateExpression: aws.String("set status = :st"),
+		UpdateExpression: aws.String("set #status = :st"),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
 		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
 			":st": {
 				S: aws.String(string(status)),
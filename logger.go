@@ -1,9 +1,12 @@
 package gommunicator
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
+	"io"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,61 +18,207 @@ const blue = "\033[34m"
 const purple = "\033[35m"
 const whiteRed = "\033[97;31m"
 
-func formatLog(info bool, values ...interface{}) []interface{} {
-	now := time.Now()
-	nowDate := now.Format("06-01-02")
-	nowTime := now.Format("15:04:05")
-	header := fmt.Sprintf("%s[   INFO   ]%s", green, reset)
+// Level of a log line
+type Level int
 
-	if !info {
-		header = fmt.Sprintf("%s[ %s! %sERROR %s! %s]%s", red, yellow, red, yellow, red, reset)
+// Log levels
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (level Level) String() string {
+	switch level {
+	case LevelDebug:
+		return "debug"
+	case LevelInfo:
+		return "info"
+	case LevelWarn:
+		return "warn"
+	default:
+		return "error"
+	}
+}
+
+// Field names carried by message handling log lines
+const (
+	FieldDataTransactionID = "data_transaction_id"
+	FieldActionID          = "action_id"
+	FieldService           = "service"
+	FieldAction            = "action"
+)
+
+// Field is a key/value pair attached to a log line
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// F returns a new Field
+func F(key string, value interface{}) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger is a leveled structured logger
+// Adapters for log/slog, zap and zerolog are in the loggers package
+type Logger interface {
+	Debug(message string, fields ...Field)
+	Info(message string, fields ...Field)
+	Warn(message string, fields ...Field)
+	Error(message string, fields ...Field)
+	// With returns a Logger adding fields to every line
+	With(fields ...Field) Logger
+}
+
+// writerLogger writes lines at or above a level to a writer with a line format
+type writerLogger struct {
+	lock   *sync.Mutex
+	out    io.Writer
+	level  Level
+	fields []Field
+	format func(now time.Time, level Level, message string, fields []Field) []byte
+}
+
+func (logger *writerLogger) log(level Level, message string, fields []Field) {
+	if level < logger.level {
+		return
+	}
+
+	line := logger.format(time.Now(), level, message, append(append([]Field{}, logger.fields...), fields...))
+
+	logger.lock.Lock()
+	defer logger.lock.Unlock()
+	logger.out.Write(line)
+}
+
+func (logger *writerLogger) Debug(message string, fields ...Field) {
+	logger.log(LevelDebug, message, fields)
+}
+
+func (logger *writerLogger) Info(message string, fields ...Field) {
+	logger.log(LevelInfo, message, fields)
+}
+
+func (logger *writerLogger) Warn(message string, fields ...Field) {
+	logger.log(LevelWarn, message, fields)
+}
+
+func (logger *writerLogger) Error(message string, fields ...Field) {
+	logger.log(LevelError, message, fields)
+}
+
+func (logger *writerLogger) With(fields ...Field) Logger {
+	with := *logger
+	with.fields = append(append([]Field{}, logger.fields...), fields...)
+	return &with
+}
+
+var levelHeaders = map[Level]string{
+	LevelDebug: fmt.Sprintf("%s[  DEBUG   ]%s", blue, reset),
+	LevelInfo:  fmt.Sprintf("%s[   INFO   ]%s", green, reset),
+	LevelWarn:  fmt.Sprintf("%s[   WARN   ]%s", yellow, reset),
+	LevelError: fmt.Sprintf("%s[ %s! %sERROR %s! %s]%s", red, yellow, red, yellow, red, reset),
+}
+
+func formatConsole(now time.Time, level Level, message string, fields []Field) []byte {
+	line := fmt.Sprintf(
+		"[%s%s%s %s%s%s] %s %s",
+		purple, now.Format("06-01-02"), reset,
+		yellow, now.Format("15:04:05"), reset,
+		levelHeaders[level], message,
+	)
+
+	for _, field := range fields {
+		line += fmt.Sprintf(" [%s%s%s: %s%v%s]", yellow, field.Key, reset, whiteRed, field.Value, reset)
 	}
 
-	ts := fmt.Sprintf("[%s%s%s %s%s%s] %s", purple, nowDate, reset, yellow, nowTime, reset, header)
+	return []byte(line + "\n")
+}
 
-	messages := []interface{}{}
+func jsonValue(value interface{}) interface{} {
+	if err, ok := value.(error); ok {
+		return err.Error()
+	}
 
-	qt := len(values) - 1
-	for i, value := range values {
-		extra := "\r\n"
-		if i == qt {
-			extra = ""
+	return value
+}
+
+func formatJSON(now time.Time, level Level, message string, fields []Field) []byte {
+	entry := make(map[string]interface{}, len(fields)+3)
+	for _, field := range fields {
+		entry[field.Key] = jsonValue(field.Value)
+	}
+
+	entry["time"] = now.UTC().Format(time.RFC3339Nano)
+	entry["level"] = level.String()
+	entry["msg"] = message
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		// Some field can't be serialized, log them as strings
+		for _, field := range fields {
+			entry[field.Key] = fmt.Sprintf("%v", field.Value)
 		}
-		messages = append(messages, fmt.Sprintf("%s %v %s", ts, value, extra))
+		line, _ = json.Marshal(entry)
 	}
 
-	return messages
+	return append(line, '\n')
 }
 
-// Logger simple logger
-type Logger struct {
-	*log.Logger
+// NewConsoleLogger returns a Logger writing colored lines to out, the default logger writes to stdout
+func NewConsoleLogger(out io.Writer, level Level) Logger {
+	return &writerLogger{lock: new(sync.Mutex), out: out, level: level, format: formatConsole}
 }
 
-func (logger *Logger) log(info bool, values ...interface{}) {
-	logger.Println(formatLog(info, values...)...)
+// NewJSONLogger returns a Logger writing one JSON object per line to out
+// Lines have the time, level and msg keys plus their fields
+func NewJSONLogger(out io.Writer, level Level) Logger {
+	return &writerLogger{lock: new(sync.Mutex), out: out, level: level, format: formatJSON}
 }
 
-var logger *Logger
+func defaultLogger() Logger {
+	return NewConsoleLogger(os.Stdout, LevelInfo)
+}
 
-func getLogger() *Logger {
+// SetLogger sets the logger, a nil logger restores the default console logger
+func (gom *Gommunicator) SetLogger(logger Logger) *Gommunicator {
 	if logger == nil {
-		newLogger := log.New(os.Stdout, "\r\n", 0)
+		logger = defaultLogger()
+	}
+
+	gom.logger = logger
+	return gom
+}
 
-		logger = &Logger{newLogger}
+func actionIDValue(actionID *string) string {
+	if actionID == nil {
+		return ""
 	}
 
-	return logger
+	return *actionID
 }
 
-func logInfo(values ...interface{}) {
-	getLogger().log(true, values...)
+func requestFields(request *DataTransactionRequest) []Field {
+	return []Field{
+		F(FieldDataTransactionID, request.ID),
+		F(FieldActionID, actionIDValue(request.ActionID)),
+		F(FieldService, request.Service),
+		F(FieldAction, request.Action),
+	}
 }
 
-func logErr(values ...interface{}) {
-	getLogger().log(false, values...)
+// responseFields returns the fields of a response, service is the responding service
+func responseFields(response *DataTransactionResponse, service string) []Field {
+	return []Field{
+		F(FieldDataTransactionID, response.ID),
+		F(FieldActionID, actionIDValue(response.ActionID)),
+		F(FieldService, service),
+		F(FieldAction, response.Action),
+	}
 }
 
-func formatWithID(id, key, message string) string {
-	return fmt.Sprintf("%s [%s%s%s: %s%s%s]", message, yellow, key, reset, whiteRed, id, reset)
+func errorField(err error) Field {
+	return F("error", strings.TrimSpace(err.Error()))
 }
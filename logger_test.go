@@ -0,0 +1,116 @@
+package gommunicator
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+func TestJSONLogger(t *testing.T) {
+	var out bytes.Buffer
+	actionID := "action"
+	request := &DataTransactionRequest{ID: "dt", Service: "orders", Action: "create", ActionID: &actionID}
+
+	logger := NewJSONLogger(&out, LevelInfo).With(F("instance", "i-1"))
+	logger.Debug("hidden")
+	logger.Info("Data transaction request received", requestFields(request)...)
+
+	if strings.Contains(out.String(), "\033") || strings.Count(out.String(), "\n") != 1 {
+		t.Fatalf("expected a single plain JSON line, got %q", out.String())
+	}
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON line: %s", err.Error())
+	}
+
+	expected := map[string]interface{}{
+		"level":                "info",
+		"msg":                  "Data transaction request received",
+		"instance":             "i-1",
+		FieldDataTransactionID: "dt",
+		FieldActionID:          "action",
+		FieldService:           "orders",
+		FieldAction:            "create",
+	}
+
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Fatalf("expected %s to be %v, got %v", key, value, entry[key])
+		}
+	}
+}
+
+func TestDuplicateLogFields(t *testing.T) {
+	var out bytes.Buffer
+	cluster := newFakeCluster()
+	orders := cluster.service("orders")
+	stock := cluster.service("stock").SetLogState(true).SetLogger(NewJSONLogger(&out, LevelInfo))
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error { return nil })
+
+	request, envelope, err := orders.newRequest(&ExecInput{DataTransactionID: "dt", Service: "stock", Action: "stock.reserve"})
+	if err != nil {
+		t.Fatalf("newRequest failed: %s", err.Error())
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"Message":           string(envelope),
+		"MessageAttributes": notificationAttributes(orders.requestAttributes(request)),
+	})
+
+	for i := 0; i < 2; i++ {
+		if err := stock.handleMessage(&sqs.Message{Body: aws.String(string(body))}); err != nil {
+			t.Fatalf("handleMessage failed: %s", err.Error())
+		}
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		json.Unmarshal([]byte(line), &entry)
+		if entry["msg"] != "Duplicated message ignored" {
+			continue
+		}
+
+		if entry[FieldDataTransactionID] != "dt" || entry[FieldActionID] != *request.ActionID || entry[FieldService] != "stock" || entry["dedup_id"] != request.DedupID {
+			t.Fatalf("expected the duplicate log to identify the request, got %v", entry)
+		}
+		return
+	}
+
+	t.Fatalf("expected the duplicate to be logged, got %s", out.String())
+}
+
+func TestUnsignedResponseLogFields(t *testing.T) {
+	var out bytes.Buffer
+	cluster := newFakeCluster()
+	orders := cluster.service("orders").SetLogState(true).SetLogger(NewJSONLogger(&out, LevelInfo))
+	stock := cluster.service("stock")
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error { return stock.Respond(request, nil) })
+
+	receiver, err := orders.Exec(&ExecInput{DataTransactionID: "dt", Service: "stock", Action: "stock.reserve", Timeout: 2})
+	if err != nil {
+		t.Fatalf("Exec failed: %s", err.Error())
+	}
+	if response := <-receiver; response == nil {
+		t.Fatalf("expected a response")
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var entry map[string]interface{}
+		json.Unmarshal([]byte(line), &entry)
+		if entry["msg"] != "Data transaction response received" {
+			continue
+		}
+
+		if entry[FieldService] != "stock" {
+			t.Fatalf("expected the responding service without signing, got %v", entry)
+		}
+		return
+	}
+
+	t.Fatalf("expected the response to be logged, got %s", out.String())
+}
@@ -0,0 +1,135 @@
+// Package loggers provides gommunicator.Logger adapters for log/slog, zap and zerolog
+//
+//	gom.SetLogger(loggers.Slog(slog.Default()))
+package loggers
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/kelvne/gommunicator"
+	"github.com/rs/zerolog"
+	"go.uber.org/zap"
+)
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+// Slog adapts a *slog.Logger
+func Slog(logger *slog.Logger) gommunicator.Logger {
+	return slogLogger{logger}
+}
+
+func slogAttrs(fields []gommunicator.Field) []slog.Attr {
+	attrs := make([]slog.Attr, len(fields))
+	for i, field := range fields {
+		attrs[i] = slog.Any(field.Key, field.Value)
+	}
+	return attrs
+}
+
+func (adapter slogLogger) log(level slog.Level, message string, fields []gommunicator.Field) {
+	adapter.logger.LogAttrs(context.Background(), level, message, slogAttrs(fields)...)
+}
+
+func (adapter slogLogger) Debug(message string, fields ...gommunicator.Field) {
+	adapter.log(slog.LevelDebug, message, fields)
+}
+
+func (adapter slogLogger) Info(message string, fields ...gommunicator.Field) {
+	adapter.log(slog.LevelInfo, message, fields)
+}
+
+func (adapter slogLogger) Warn(message string, fields ...gommunicator.Field) {
+	adapter.log(slog.LevelWarn, message, fields)
+}
+
+func (adapter slogLogger) Error(message string, fields ...gommunicator.Field) {
+	adapter.log(slog.LevelError, message, fields)
+}
+
+func (adapter slogLogger) With(fields ...gommunicator.Field) gommunicator.Logger {
+	args := make([]interface{}, len(fields))
+	for i, attr := range slogAttrs(fields) {
+		args[i] = attr
+	}
+	return slogLogger{adapter.logger.With(args...)}
+}
+
+type zapLogger struct {
+	logger *zap.Logger
+}
+
+// Zap adapts a *zap.Logger
+func Zap(logger *zap.Logger) gommunicator.Logger {
+	return zapLogger{logger}
+}
+
+func zapFields(fields []gommunicator.Field) []zap.Field {
+	zapped := make([]zap.Field, len(fields))
+	for i, field := range fields {
+		zapped[i] = zap.Any(field.Key, field.Value)
+	}
+	return zapped
+}
+
+func (adapter zapLogger) Debug(message string, fields ...gommunicator.Field) {
+	adapter.logger.Debug(message, zapFields(fields)...)
+}
+
+func (adapter zapLogger) Info(message string, fields ...gommunicator.Field) {
+	adapter.logger.Info(message, zapFields(fields)...)
+}
+
+func (adapter zapLogger) Warn(message string, fields ...gommunicator.Field) {
+	adapter.logger.Warn(message, zapFields(fields)...)
+}
+
+func (adapter zapLogger) Error(message string, fields ...gommunicator.Field) {
+	adapter.logger.Error(message, zapFields(fields)...)
+}
+
+func (adapter zapLogger) With(fields ...gommunicator.Field) gommunicator.Logger {
+	return zapLogger{adapter.logger.With(zapFields(fields)...)}
+}
+
+type zerologLogger struct {
+	logger zerolog.Logger
+}
+
+// Zerolog adapts a zerolog.Logger
+func Zerolog(logger zerolog.Logger) gommunicator.Logger {
+	return zerologLogger{logger}
+}
+
+func zerologEvent(event *zerolog.Event, message string, fields []gommunicator.Field) {
+	for _, field := range fields {
+		event = event.Interface(field.Key, field.Value)
+	}
+	event.Msg(message)
+}
+
+func (adapter zerologLogger) Debug(message string, fields ...gommunicator.Field) {
+	zerologEvent(adapter.logger.Debug(), message, fields)
+}
+
+func (adapter zerologLogger) Info(message string, fields ...gommunicator.Field) {
+	zerologEvent(adapter.logger.Info(), message, fields)
+}
+
+func (adapter zerologLogger) Warn(message string, fields ...gommunicator.Field) {
+	zerologEvent(adapter.logger.Warn(), message, fields)
+}
+
+func (adapter zerologLogger) Error(message string, fields ...gommunicator.Field) {
+	zerologEvent(adapter.logger.Error(), message, fields)
+}
+
+func (adapter zerologLogger) With(fields ...gommunicator.Field) gommunicator.Logger {
+	context := adapter.logger.With()
+	for _, field := range fields {
+		context = context.Interface(field.Key, field.Value)
+	}
+	return zerologLogger{context.Logger()}
+}
@@ -4,9 +4,17 @@ package gommunicator
 type ErrType string
 
 // Error types
+// Types from ValidationErrorType on are identifiers, their titles in each locale come from the ErrorCatalog
 const (
-	InternalErrorType ErrType = "Aconteceu um erro interno!"
-	SimpleErrorType           = "Aconteceu um erro!"
+	InternalErrorType     ErrType = "Aconteceu um erro interno!"
+	SimpleErrorType       ErrType = "Aconteceu um erro!"
+	ValidationErrorType   ErrType = "validation"
+	NotFoundErrorType     ErrType = "not_found"
+	ConflictErrorType     ErrType = "conflict"
+	UnauthorizedErrorType ErrType = "unauthorized"
+	RateLimitedErrorType  ErrType = "rate_limited"
+	TimeoutErrorType      ErrType = "timeout"
+	UnavailableErrorType  ErrType = "unavailable"
 )
 
 // MapErr interface
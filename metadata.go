@@ -0,0 +1,86 @@
+package gommunicator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// Well known headers
+const (
+	HeaderTenantID = "tenant-id"
+	HeaderUserID   = "user-id"
+	HeaderLocale   = "locale"
+)
+
+// defaultAttributeHeaders are the headers mapped onto SNS message attributes by default
+var defaultAttributeHeaders = []string{HeaderTenantID}
+
+func copyHeaders(headers map[string]string) map[string]string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	copied := make(map[string]string, len(headers))
+	for key, value := range headers {
+		copied[key] = value
+	}
+
+	return copied
+}
+
+// mergeHeaders returns base overridden by override
+func mergeHeaders(base, override map[string]string) map[string]string {
+	merged := copyHeaders(base)
+	if merged == nil && len(override) > 0 {
+		merged = make(map[string]string, len(override))
+	}
+
+	for key, value := range override {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// ErrReservedAttribute is returned when an attribute header has the name of an attribute set by the Gommunicator
+var ErrReservedAttribute = errors.New("reserved message attribute")
+
+func isReservedAttribute(name string) bool {
+	if name == "Service" || name == "Action" || name == packedAttribute {
+		return true
+	}
+
+	for _, transport := range transportAttributes {
+		if name == transport {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetAttributeHeaders sets the headers mapped onto SNS message attributes, usable on filter policies
+// By default only HeaderTenantID is mapped, SNS accepts at most 10 attributes per message:
+// with Service, Action and the packed transport attributes, up to 7 headers fit
+// Headers named as the attributes set by the Gommunicator (Service, Action, Signature...) are rejected
+func (gom *Gommunicator) SetAttributeHeaders(headers ...string) *Gommunicator {
+	for _, header := range headers {
+		if isReservedAttribute(header) {
+			gom.onErr(fmt.Errorf("%w: %s can't be an attribute header", ErrReservedAttribute, header))
+			return gom
+		}
+	}
+
+	gom.attributeHeaders = headers
+	return gom
+}
+
+func (gom *Gommunicator) setHeaderAttributes(attributes map[string]*sns.MessageAttributeValue, headers map[string]string) {
+	for _, header := range gom.attributeHeaders {
+		if value, ok := headers[header]; ok && value != "" {
+			attributes[header] = stringAttribute(value)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package gommunicator
+
+import "time"
+
+// Message kinds reported to Metrics
+const (
+	KindRequest  = "request"
+	KindResponse = "response"
+)
+
+// UnknownAction labels the metrics of incoming messages whose action is not known to the service
+const UnknownAction = "unknown"
+
+// Metrics receives the measurements of a Gommunicator
+// Actions of incoming messages are those registered, or the one sent for responses, else UnknownAction,
+// so a sender can't grow the label cardinality
+// The default implementation discards them, a Prometheus implementation is in the metrics package
+type Metrics interface {
+	// MessageReceived counts a request or response read from the queue
+	MessageReceived(kind, action string)
+	// MessageProcessed counts a handled request or response and observes the handling latency
+	MessageProcessed(kind, action string, success bool, duration time.Duration)
+	// ExecCompleted observes the round trip of an Exec, timedOut when no response arrived
+	ExecCompleted(service, action string, duration time.Duration, timedOut bool)
+	// CallbackNotFound counts responses without a pending Exec
+	CallbackNotFound(action string)
+	// DuplicateMessage counts messages already handled
+	DuplicateMessage(kind, action string)
+	// PublishFailed counts messages that could not be published
+	PublishFailed(kind, action string)
+	// PendingCallbacks sets the number of Exec waiting for a response
+	PendingCallbacks(count int)
+	// QueuePolled observes the latency of a queue poll and the number of messages read
+	QueuePolled(duration time.Duration, messages int)
+	// MessageSize observes the size in bytes of a published body before and after compression, equal when not compressed
+	MessageSize(raw, compressed int)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) MessageReceived(kind, action string)                                         {}
+func (noopMetrics) MessageProcessed(kind, action string, success bool, duration time.Duration)  {}
+func (noopMetrics) ExecCompleted(service, action string, duration time.Duration, timedOut bool) {}
+func (noopMetrics) CallbackNotFound(action string)                                              {}
+func (noopMetrics) DuplicateMessage(kind, action string)                                        {}
+func (noopMetrics) PublishFailed(kind, action string)                                           {}
+func (noopMetrics) PendingCallbacks(count int)                                                  {}
+func (noopMetrics) QueuePolled(duration time.Duration, messages int)                            {}
+func (noopMetrics) MessageSize(raw, compressed int)                                             {}
+
+// metricAction returns the registered action serving a request action, UnknownAction when none does
+func (gom *Gommunicator) metricAction(action string) string {
+	if rt, ok := gom.actions.lookup(action); ok {
+		return rt.action
+	}
+
+	return UnknownAction
+}
+
+// responseMetricAction returns the action of the Exec waiting for a response, UnknownAction when none is
+func responseMetricAction(response *DataTransactionResponse) string {
+	if _, action, ok := pendingExec(response.ActionID); ok {
+		return action
+	}
+
+	return UnknownAction
+}
+
+// SetMetrics sets the metrics, nil discards them
+func (gom *Gommunicator) SetMetrics(metrics Metrics) *Gommunicator {
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+
+	gom.metrics = metrics
+	return gom
+}
@@ -0,0 +1,185 @@
+// Package metrics provides a Prometheus implementation of gommunicator.Metrics
+//
+//	prom, err := metrics.NewPrometheus(prometheus.DefaultRegisterer)
+//	gom.SetMetrics(prom)
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/kelvne/gommunicator"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "gommunicator"
+
+// Prometheus records the gommunicator metrics as Prometheus collectors
+type Prometheus struct {
+	received         *prometheus.CounterVec
+	processed        *prometheus.CounterVec
+	handlerDuration  *prometheus.HistogramVec
+	execDuration     *prometheus.HistogramVec
+	execTimeouts     *prometheus.CounterVec
+	callbackNotFound *prometheus.CounterVec
+	duplicates       *prometheus.CounterVec
+	publishErrors    *prometheus.CounterVec
+	pendingCallbacks prometheus.Gauge
+	pollDuration     prometheus.Histogram
+	polledMessages   prometheus.Counter
+	rawSize          prometheus.Histogram
+	compressedSize   prometheus.Histogram
+}
+
+// NewPrometheus returns a Prometheus metrics registering its collectors on registerer
+func NewPrometheus(registerer prometheus.Registerer) (*Prometheus, error) {
+	metrics := &Prometheus{
+		received: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_received_total",
+			Help:      "Requests and responses read from the queue.",
+		}, []string{"kind", "action"}),
+		processed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "messages_processed_total",
+			Help:      "Requests and responses handled.",
+		}, []string{"kind", "action", "success"}),
+		handlerDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "handler_duration_seconds",
+			Help:      "Latency of the request and response handling.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"kind", "action"}),
+		execDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "exec_duration_seconds",
+			Help:      "Round trip latency of Exec, up to the response or the timeout.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "action"}),
+		execTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "exec_timeouts_total",
+			Help:      "Exec calls without a response before their timeout.",
+		}, []string{"service", "action"}),
+		callbackNotFound: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "callbacks_not_found_total",
+			Help:      "Responses received without a pending Exec.",
+		}, []string{"action"}),
+		duplicates: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "duplicate_messages_total",
+			Help:      "Messages already handled, according to their dedup id.",
+		}, []string{"kind", "action"}),
+		publishErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "publish_errors_total",
+			Help:      "Requests and responses that could not be published.",
+		}, []string{"kind", "action"}),
+		pendingCallbacks: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pending_callbacks",
+			Help:      "Exec calls waiting for a response.",
+		}),
+		pollDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "poll_duration_seconds",
+			Help:      "Latency of the queue polls.",
+			Buckets:   []float64{.05, .1, .5, 1, 2.5, 5, 10, 20},
+		}),
+		polledMessages: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "polled_messages_total",
+			Help:      "Messages read by the queue polls.",
+		}),
+		rawSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "message_size_bytes",
+			Help:      "Size of the published bodies before compression.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 6),
+		}),
+		compressedSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "message_compressed_size_bytes",
+			Help:      "Size of the published bodies after compression, the raw size when not compressed.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 6),
+		}),
+	}
+
+	collectors := []prometheus.Collector{
+		metrics.received,
+		metrics.processed,
+		metrics.handlerDuration,
+		metrics.execDuration,
+		metrics.execTimeouts,
+		metrics.callbackNotFound,
+		metrics.duplicates,
+		metrics.publishErrors,
+		metrics.pendingCallbacks,
+		metrics.pollDuration,
+		metrics.polledMessages,
+		metrics.rawSize,
+		metrics.compressedSize,
+	}
+
+	for _, collector := range collectors {
+		if err := registerer.Register(collector); err != nil {
+			return nil, err
+		}
+	}
+
+	return metrics, nil
+}
+
+// MessageReceived implements gommunicator.Metrics
+func (metrics *Prometheus) MessageReceived(kind, action string) {
+	metrics.received.WithLabelValues(kind, action).Inc()
+}
+
+// MessageProcessed implements gommunicator.Metrics
+func (metrics *Prometheus) MessageProcessed(kind, action string, success bool, duration time.Duration) {
+	metrics.processed.WithLabelValues(kind, action, strconv.FormatBool(success)).Inc()
+	metrics.handlerDuration.WithLabelValues(kind, action).Observe(duration.Seconds())
+}
+
+// ExecCompleted implements gommunicator.Metrics
+func (metrics *Prometheus) ExecCompleted(service, action string, duration time.Duration, timedOut bool) {
+	metrics.execDuration.WithLabelValues(service, action).Observe(duration.Seconds())
+	if timedOut {
+		metrics.execTimeouts.WithLabelValues(service, action).Inc()
+	}
+}
+
+// CallbackNotFound implements gommunicator.Metrics
+func (metrics *Prometheus) CallbackNotFound(action string) {
+	metrics.callbackNotFound.WithLabelValues(action).Inc()
+}
+
+// DuplicateMessage implements gommunicator.Metrics
+func (metrics *Prometheus) DuplicateMessage(kind, action string) {
+	metrics.duplicates.WithLabelValues(kind, action).Inc()
+}
+
+// PublishFailed implements gommunicator.Metrics
+func (metrics *Prometheus) PublishFailed(kind, action string) {
+	metrics.publishErrors.WithLabelValues(kind, action).Inc()
+}
+
+// PendingCallbacks implements gommunicator.Metrics
+func (metrics *Prometheus) PendingCallbacks(count int) {
+	metrics.pendingCallbacks.Set(float64(count))
+}
+
+// QueuePolled implements gommunicator.Metrics
+func (metrics *Prometheus) QueuePolled(duration time.Duration, messages int) {
+	metrics.pollDuration.Observe(duration.Seconds())
+	metrics.polledMessages.Add(float64(messages))
+}
+
+// MessageSize implements gommunicator.Metrics
+func (metrics *Prometheus) MessageSize(raw, compressed int) {
+	metrics.rawSize.Observe(float64(raw))
+	metrics.compressedSize.Observe(float64(compressed))
+}
+
+var _ gommunicator.Metrics = (*Prometheus)(nil)
@@ -0,0 +1,81 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// labelMetrics records the action labels of the received messages and missing callbacks
+type labelMetrics struct {
+	noopMetrics
+	lock     sync.Mutex
+	received []string
+	notFound []string
+}
+
+func (metrics *labelMetrics) MessageReceived(kind, action string) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.received = append(metrics.received, kind+":"+action)
+}
+
+func (metrics *labelMetrics) CallbackNotFound(action string) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.notFound = append(metrics.notFound, action)
+}
+
+func (metrics *labelMetrics) labels() (string, string) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	received := append([]string{}, metrics.received...)
+	sort.Strings(received)
+	return strings.Join(received, ","), strings.Join(metrics.notFound, ",")
+}
+
+func TestMetricActionLabels(t *testing.T) {
+	sent, handled := new(labelMetrics), new(labelMetrics)
+
+	cluster := newFakeCluster()
+	orders := cluster.service("orders").SetMetrics(sent)
+	stock := cluster.service("stock").SetMetrics(handled)
+	reply := func(request *DataTransactionRequest) error { return stock.Respond(request, "ok") }
+	stock.RegisterAction("stock.reserve", reply).RegisterAction("stock.events.*", reply)
+
+	for _, action := range []string{"stock.reserve", "stock.events.created", "stock.a8f3c2e1"} {
+		receiver, err := orders.Exec(&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: action, Timeout: 1})
+		if err != nil {
+			t.Fatalf("Exec failed: %s", err.Error())
+		}
+
+		// Actions not registered get no response
+		if response := <-receiver; response == nil && action != "stock.a8f3c2e1" {
+			t.Fatalf("expected a response to %s", action)
+		}
+	}
+
+	// A response nothing waits for, with a made up action
+	forged, _ := json.Marshal(map[string]interface{}{
+		"Message":           `{"id": "dt-2", "dedupId": "forged", "action": "forged.f00d", "actionId": "forged", "success": true}`,
+		"MessageAttributes": map[string]interface{}{"Service": map[string]interface{}{"Type": "String", "Value": "orders"}},
+	})
+	if err := orders.handleMessage(&sqs.Message{Body: aws.String(string(forged))}); err != nil {
+		t.Fatalf("handleMessage failed: %s", err.Error())
+	}
+
+	received, _ := handled.labels()
+	if received != "request:stock.events.*,request:stock.reserve,request:unknown" {
+		t.Fatalf("expected the registered actions or unknown as labels, got %s", received)
+	}
+
+	received, notFound := sent.labels()
+	if received != "response:stock.events.created,response:stock.reserve,response:unknown" || notFound != "unknown" {
+		t.Fatalf("expected the sent actions or unknown as labels, got %s and %s", received, notFound)
+	}
+}
@@ -0,0 +1,434 @@
+package gommunicator
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// Outbox stores messages in a table inside the transaction of the caller, a relay publishes them once committed
+// Messages carry their dedup ID, so one published again after a crash is ignored by its receiver
+// The relay publishes the messages of a DataTransactionID in insertion order, a failed message holds back the ones after it
+// Failed messages are retried with a growing delay, then parked after MaxAttempts: parked_at is set and the
+// messages after it are sent, clear parked_at to send it again
+// Relays claim the messages they send by pushing next_attempt_at, so several instances can share the table
+//
+//	CREATE TABLE outbox (
+//		id BIGSERIAL PRIMARY KEY, data_transaction_id VARCHAR(64), kind VARCHAR(16), action VARCHAR(255),
+//		body TEXT, attributes TEXT, created_at TIMESTAMP, attempts INT, last_error TEXT,
+//		next_attempt_at TIMESTAMP, sent_at TIMESTAMP NULL, parked_at TIMESTAMP NULL
+//	)
+//	CREATE INDEX outbox_pending ON outbox (sent_at, parked_at, next_attempt_at)
+type Outbox struct {
+	DB          *sql.DB
+	Table       string
+	Placeholder func(n int) string // ? by default, use DollarPlaceholder for PostgreSQL
+	Interval    time.Duration      // Time between relay polls, 1 second by default
+	BatchSize   int                // Messages read per poll, 100 by default
+	MaxAttempts int                // Attempts before a message is parked, 10 by default
+	RetryDelay  time.Duration      // Delay after the first failed attempt, doubled on every attempt up to 10 minutes, 1 second by default
+	Lease       time.Duration      // Time a relay owns the messages it claimed, 30 seconds by default
+}
+
+const maxOutboxRetryDelay = 10 * time.Minute
+
+// NewOutbox returns a new Outbox storing messages in table
+func NewOutbox(db *sql.DB, table string) *Outbox {
+	return &Outbox{DB: db, Table: table}
+}
+
+// SetOutbox sets the outbox used by ExecTx and RespondTx, its relay runs while Start does
+func (gom *Gommunicator) SetOutbox(outbox *Outbox) *Gommunicator {
+	gom.outbox = outbox
+	return gom
+}
+
+// outboxMessage is a message waiting in the outbox
+type outboxMessage struct {
+	id                int64
+	dataTransactionID string
+	kind              string
+	action            string
+	body              []byte
+	attributes        map[string]string
+	attempts          int
+}
+
+func (outbox *Outbox) placeholders(from, count int) []string {
+	placeholders := make([]string, count)
+	for i := range placeholders {
+		if outbox.Placeholder == nil {
+			placeholders[i] = "?"
+		} else {
+			placeholders[i] = outbox.Placeholder(from + i)
+		}
+	}
+	return placeholders
+}
+
+// insert writes a message inside tx
+func (outbox *Outbox) insert(tx *sql.Tx, message *outboxMessage) error {
+	attributes, err := json.Marshal(message.attributes)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (data_transaction_id, kind, action, body, attributes, created_at, attempts, next_attempt_at) VALUES (%s)",
+		outbox.Table, strings.Join(outbox.placeholders(1, 8), ", "),
+	)
+
+	now := time.Now().UTC()
+	_, err = tx.Exec(query, message.dataTransactionID, message.kind, message.action, string(message.body), string(attributes), now, 0, now)
+	return err
+}
+
+// pending reads the oldest messages due, skipping the ones held back by an earlier message of their
+// data transaction waiting for a retry or claimed by another relay
+func (outbox *Outbox) pending() ([]*outboxMessage, error) {
+	batchSize := outbox.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	placeholders := outbox.placeholders(1, 2)
+	now := time.Now().UTC()
+	rows, err := outbox.DB.Query(fmt.Sprintf(
+		`SELECT id, data_transaction_id, kind, action, body, attributes, attempts FROM %[1]s message
+		WHERE sent_at IS NULL AND parked_at IS NULL AND next_attempt_at <= %[2]s AND NOT EXISTS (
+			SELECT 1 FROM %[1]s earlier WHERE earlier.data_transaction_id = message.data_transaction_id
+			AND earlier.data_transaction_id <> '' AND earlier.id < message.id
+			AND earlier.sent_at IS NULL AND earlier.parked_at IS NULL AND earlier.next_attempt_at > %[3]s
+		) ORDER BY id LIMIT %[4]d`,
+		outbox.Table, placeholders[0], placeholders[1], batchSize,
+	), now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]*outboxMessage, 0)
+	for rows.Next() {
+		message := new(outboxMessage)
+		var body, attributes string
+		if err := rows.Scan(&message.id, &message.dataTransactionID, &message.kind, &message.action, &body, &attributes, &message.attempts); err != nil {
+			return nil, err
+		}
+
+		message.body = []byte(body)
+		if err := json.Unmarshal([]byte(attributes), &message.attributes); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+// claim makes the relay the owner of a message for a lease, false when another relay claimed or sent it first
+// A relay crashing before marking the message lets it be sent again once the lease is over
+func (outbox *Outbox) claim(message *outboxMessage) (bool, error) {
+	lease := outbox.Lease
+	if lease <= 0 {
+		lease = 30 * time.Second
+	}
+
+	placeholders := outbox.placeholders(1, 3)
+	now := time.Now().UTC()
+	result, err := outbox.DB.Exec(
+		fmt.Sprintf(
+			"UPDATE %s SET next_attempt_at = %s WHERE id = %s AND sent_at IS NULL AND parked_at IS NULL AND next_attempt_at <= %s",
+			outbox.Table, placeholders[0], placeholders[1], placeholders[2],
+		),
+		now.Add(lease), message.id, now,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	claimed, err := result.RowsAffected()
+	return claimed == 1, err
+}
+
+// markSent records a published message
+func (outbox *Outbox) markSent(message *outboxMessage) error {
+	placeholders := outbox.placeholders(1, 2)
+	_, err := outbox.DB.Exec(
+		fmt.Sprintf("UPDATE %s SET sent_at = %s, attempts = attempts + 1 WHERE id = %s", outbox.Table, placeholders[0], placeholders[1]),
+		time.Now().UTC(), message.id,
+	)
+	return err
+}
+
+// retryDelay is the delay after a failed attempt, doubled on every attempt
+func (outbox *Outbox) retryDelay(attempts int) time.Duration {
+	delay := outbox.RetryDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	for i := 1; i < attempts && delay < maxOutboxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxOutboxRetryDelay {
+		delay = maxOutboxRetryDelay
+	}
+	return delay
+}
+
+// markFailed records a failed attempt, the message is retried after a delay or parked after MaxAttempts
+// It returns whether the message was parked
+func (outbox *Outbox) markFailed(message *outboxMessage, cause error) (bool, error) {
+	maxAttempts := outbox.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 10
+	}
+
+	attempts := message.attempts + 1
+	now := time.Now().UTC()
+	var parkedAt *time.Time
+	if attempts >= maxAttempts {
+		parkedAt = &now
+	}
+
+	placeholders := outbox.placeholders(1, 5)
+	_, err := outbox.DB.Exec(
+		fmt.Sprintf(
+			"UPDATE %s SET attempts = %s, last_error = %s, next_attempt_at = %s, parked_at = %s WHERE id = %s",
+			outbox.Table, placeholders[0], placeholders[1], placeholders[2], placeholders[3], placeholders[4],
+		),
+		attempts, cause.Error(), now.Add(outbox.retryDelay(attempts)), parkedAt, message.id,
+	)
+	return parkedAt != nil, err
+}
+
+// snsAttributes rebuilds the message attributes of an outbox message
+func (message *outboxMessage) snsAttributes() map[string]*sns.MessageAttributeValue {
+	attributes := make(map[string]*sns.MessageAttributeValue, len(message.attributes))
+	for name, value := range message.attributes {
+		attributes[name] = stringAttribute(value)
+	}
+	return attributes
+}
+
+var errNoOutbox = errors.New("no outbox set")
+
+// ExecTx stores a request in the outbox inside tx, it is sent once tx is committed
+// Nothing waits for the response, so the request is flagged NoResponse and its handler sends none,
+// the target action should act on its own or Exec back
+// The trace context of input.Context is sent along the request
+func (gom *Gommunicator) ExecTx(tx *sql.Tx, input *ExecInput) (actionID string, err error) {
+	if gom.outbox == nil {
+		return "", errNoOutbox
+	}
+
+	flagged := *input
+	flagged.noResponse = true
+
+	request, bytesMessage, err := gom.newRequest(&flagged)
+	if err != nil {
+		return "", err
+	}
+
+	attributes := gom.requestAttributes(request)
+	if input.Context != nil {
+		injectTraceContext(input.Context, attributes)
+	}
+
+	err = gom.outbox.insert(tx, &outboxMessage{
+		dataTransactionID: request.ID,
+		kind:              KindRequest,
+		action:            request.Action,
+		body:              bytesMessage,
+		attributes:        attributeValues(attributes),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return *request.ActionID, nil
+}
+
+// RespondTx stores the response to a request in the outbox inside tx, it is sent once tx is committed
+func (gom *Gommunicator) RespondTx(tx *sql.Tx, request *DataTransactionRequest, payload interface{}) error {
+	if gom.outbox == nil {
+		return errNoOutbox
+	}
+
+	response, err := gom.successResponse(request, payload, request.Headers)
+	if err != nil {
+		return err
+	}
+
+	return gom.storeResponse(tx, request, response)
+}
+
+// RespondErrorTx stores the error response to a request in the outbox inside tx, it is sent once tx is committed
+func (gom *Gommunicator) RespondErrorTx(tx *sql.Tx, request *DataTransactionRequest, mapErr MapErr) error {
+	if gom.outbox == nil {
+		return errNoOutbox
+	}
+
+	return gom.storeResponse(tx, request, gom.errorResponse(request, mapErr, request.Headers))
+}
+
+func (gom *Gommunicator) storeResponse(tx *sql.Tx, request *DataTransactionRequest, response *DataTransactionResponse) error {
+	if request.NoResponse {
+		gom.tryLogDebug("Data transaction response dropped, the request expects none", requestFields(request)...)
+		return nil
+	}
+
+	bytesMessage, attributes, err := gom.responseMessage(request, response)
+	if err != nil {
+		return err
+	}
+	injectTraceContext(request.Context(), attributes)
+
+	return gom.outbox.insert(tx, &outboxMessage{
+		dataTransactionID: request.ID,
+		kind:              KindResponse,
+		action:            request.Action,
+		body:              bytesMessage,
+		attributes:        attributeValues(attributes),
+	})
+}
+
+// relayOutbox publishes the pending messages of a poll, returning how many were sent
+// Once a message of a data transaction fails or is claimed by another relay, its later messages wait for the next poll
+func (gom *Gommunicator) relayOutbox() (int, error) {
+	messages, err := gom.outbox.pending()
+	if err != nil {
+		return 0, err
+	}
+
+	held := make(map[string]bool)
+	sent := 0
+	for _, message := range messages {
+		ordered := message.dataTransactionID != ""
+		if ordered && held[message.dataTransactionID] {
+			continue
+		}
+
+		claimed, err := gom.outbox.claim(message)
+		if err != nil {
+			return sent, err
+		}
+
+		if !claimed {
+			held[message.dataTransactionID] = ordered
+			continue
+		}
+
+		fields := []Field{F(FieldDataTransactionID, message.dataTransactionID), F(FieldAction, message.action), F("attempts", message.attempts+1)}
+		if err := gom.publish(message.body, message.snsAttributes()); err != nil {
+			action := message.action
+			if message.kind == KindResponse {
+				action = gom.metricAction(action)
+			}
+			gom.metrics.PublishFailed(message.kind, action)
+			gom.tryLogErr("Outbox message could not be sent", append(fields, errorField(err))...)
+			held[message.dataTransactionID] = ordered
+
+			parked, err := gom.outbox.markFailed(message, err)
+			if err != nil {
+				return sent, err
+			}
+
+			if parked {
+				gom.tryLogErr("Outbox message parked, it is not retried anymore", fields...)
+			}
+			continue
+		}
+
+		// A failure here publishes the message again once its lease is over, its receiver ignores it as a duplicate
+		if err := gom.outbox.markSent(message); err != nil {
+			return sent, err
+		}
+		gom.recordRelayed(message)
+		sent++
+	}
+
+	return sent, nil
+}
+
+// recordRelayed audits and records on the timeline a relayed message, as Exec and Respond do once published
+func (gom *Gommunicator) recordRelayed(message *outboxMessage) {
+	if message.kind == KindRequest {
+		request := new(DataTransactionRequest)
+		if err := json.Unmarshal(message.body, request); err != nil {
+			gom.tryLogErr("Outbox request could not be recorded", F(FieldDataTransactionID, message.dataTransactionID), F(FieldAction, message.action), errorField(err))
+			return
+		}
+
+		gom.tryLogInfo("Data transaction request sent", requestFields(request)...)
+		gom.recordEvent(request.ID, &TimelineEvent{
+			Type:     EventRequestSent,
+			Peer:     request.Service,
+			Action:   request.Action,
+			ActionID: actionIDValue(request.ActionID),
+		})
+		return
+	}
+
+	response := new(DataTransactionResponse)
+	if err := json.Unmarshal(message.body, response); err != nil {
+		gom.tryLogErr("Outbox response could not be recorded", F(FieldDataTransactionID, message.dataTransactionID), F(FieldAction, message.action), errorField(err))
+		return
+	}
+
+	// The request is rebuilt from the response, the caller is the service the response was sent to
+	request := &DataTransactionRequest{
+		ID:              response.ID,
+		Service:         gom.ServiceName,
+		Action:          response.Action,
+		IncomingService: message.attributes["Service"],
+		ActionID:        response.ActionID,
+	}
+
+	gom.auditResponse(request, response)
+	gom.recordEvent(request.ID, (&TimelineEvent{
+		Type:     EventResponseSent,
+		Peer:     request.IncomingService,
+		Action:   request.Action,
+		ActionID: actionIDValue(request.ActionID),
+	}).responseOutcome(response))
+}
+
+func (gom *Gommunicator) runOutboxRelay() {
+	interval := gom.outbox.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gom.stop:
+			return
+		case <-ticker.C:
+			sent, err := gom.relayOutbox()
+			if err != nil {
+				gom.onErr(err)
+			}
+
+			if sent > 0 {
+				gom.tryLogDebug("Outbox messages sent", F(FieldService, gom.ServiceName), F("sent", sent))
+			}
+		}
+	}
+}
+
+func (gom *Gommunicator) startOutboxRelay() {
+	if gom.outbox != nil {
+		go gom.runOutboxRelay()
+	}
+}
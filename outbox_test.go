@@ -0,0 +1,299 @@
+package gommunicator
+
+import (
+	"database/sql"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+func testOutbox(t *testing.T) *Outbox {
+	db, err := sql.Open("sqlite", "file:"+t.Name()+"?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatalf("sql.Open failed: %s", err.Error())
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, data_transaction_id TEXT, kind TEXT, action TEXT,
+		body TEXT, attributes TEXT, created_at TIMESTAMP, attempts INT, last_error TEXT,
+		next_attempt_at TIMESTAMP, sent_at TIMESTAMP NULL, parked_at TIMESTAMP NULL
+	)`)
+	if err != nil {
+		t.Fatalf("create table failed: %s", err.Error())
+	}
+
+	return NewOutbox(db, "outbox")
+}
+
+func execTx(t *testing.T, gom *Gommunicator, inputs ...*ExecInput) {
+	tx, err := gom.outbox.DB.Begin()
+	if err != nil {
+		t.Fatalf("Begin failed: %s", err.Error())
+	}
+
+	for _, input := range inputs {
+		if _, err := gom.ExecTx(tx, input); err != nil {
+			t.Fatalf("ExecTx failed: %s", err.Error())
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed: %s", err.Error())
+	}
+}
+
+func publishedActions(cluster *fakeCluster) []string {
+	actions := make([]string, 0)
+	for _, attributes := range cluster.publications() {
+		actions = append(actions, attributes["Action"])
+	}
+	return actions
+}
+
+// dueNow makes the messages waiting for a retry due
+func dueNow(t *testing.T, outbox *Outbox) {
+	if _, err := outbox.DB.Exec("UPDATE outbox SET next_attempt_at = ?", time.Now().UTC().Add(-time.Second)); err != nil {
+		t.Fatalf("update failed: %s", err.Error())
+	}
+}
+
+func TestOutboxOrdering(t *testing.T) {
+	cluster := newFakeCluster()
+	outbox := testOutbox(t)
+	outbox.RetryDelay = time.Hour
+	orders := cluster.service("orders").SetOutbox(outbox)
+
+	failing := true
+	cluster.failPublish = func(attributes map[string]string) error {
+		if failing && attributes["Action"] == "stock.reserve" {
+			return errors.New("throttled")
+		}
+		return nil
+	}
+
+	execTx(t, orders,
+		&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve"},
+		&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.commit"},
+		&ExecInput{DataTransactionID: "dt-2", Service: "payments", Action: "payments.charge"},
+	)
+
+	if sent, err := orders.relayOutbox(); err != nil || sent != 1 {
+		t.Fatalf("expected only the message of dt-2 to be sent, got %d: %v", sent, err)
+	}
+
+	// stock.reserve waits for its retry and holds stock.commit back
+	if sent, err := orders.relayOutbox(); err != nil || sent != 0 {
+		t.Fatalf("expected dt-1 to be held back, got %d sent: %v", sent, err)
+	}
+
+	failing = false
+	dueNow(t, outbox)
+
+	if sent, err := orders.relayOutbox(); err != nil || sent != 2 {
+		t.Fatalf("expected dt-1 to be sent, got %d: %v", sent, err)
+	}
+
+	actions := publishedActions(cluster)
+	if len(actions) != 3 || actions[0] != "payments.charge" || actions[1] != "stock.reserve" || actions[2] != "stock.commit" {
+		t.Fatalf("expected dt-1 in insertion order, got %v", actions)
+	}
+}
+
+func TestOutboxParking(t *testing.T) {
+	cluster := newFakeCluster()
+	outbox := testOutbox(t)
+	outbox.MaxAttempts = 2
+	outbox.BatchSize = 1
+	orders := cluster.service("orders").SetOutbox(outbox)
+
+	cluster.failPublish = func(attributes map[string]string) error {
+		if attributes["Action"] == "stock.reserve" {
+			return errors.New("message too large")
+		}
+		return nil
+	}
+
+	execTx(t, orders,
+		&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve"},
+		&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.commit"},
+		&ExecInput{DataTransactionID: "dt-2", Service: "payments", Action: "payments.charge"},
+	)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if sent, err := orders.relayOutbox(); err != nil || sent != 0 {
+			t.Fatalf("expected stock.reserve to fail, got %d sent: %v", sent, err)
+		}
+		dueNow(t, outbox)
+	}
+
+	var attempts int
+	var lastError string
+	var parkedAt sql.NullTime
+	row := outbox.DB.QueryRow("SELECT attempts, last_error, parked_at FROM outbox WHERE action = 'stock.reserve'")
+	if err := row.Scan(&attempts, &lastError, &parkedAt); err != nil {
+		t.Fatalf("scan failed: %s", err.Error())
+	}
+
+	if attempts != 2 || lastError != "message too large" || !parkedAt.Valid {
+		t.Fatalf("expected the message to be parked after 2 attempts, got %d: %s", attempts, lastError)
+	}
+
+	// The parked message neither fills the batch nor holds its data transaction back
+	for _, expected := range []string{"stock.commit", "payments.charge"} {
+		if sent, err := orders.relayOutbox(); err != nil || sent != 1 {
+			t.Fatalf("expected %s to be sent, got %d: %v", expected, sent, err)
+		}
+	}
+
+	if actions := publishedActions(cluster); len(actions) != 2 {
+		t.Fatalf("expected the parked message not to be sent, got %v", actions)
+	}
+}
+
+func TestOutboxClaim(t *testing.T) {
+	cluster := newFakeCluster()
+	outbox := testOutbox(t)
+	orders := cluster.service("orders").SetOutbox(outbox)
+	replica := cluster.service("orders-replica").SetOutbox(outbox)
+
+	execTx(t, orders, &ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve"})
+
+	messages, err := outbox.pending()
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("expected a pending message, got %d: %v", len(messages), err)
+	}
+
+	if claimed, err := outbox.claim(messages[0]); err != nil || !claimed {
+		t.Fatalf("expected the message to be claimed, got %v", err)
+	}
+
+	// Another relay reading the table meanwhile gets nothing
+	if sent, err := replica.relayOutbox(); err != nil || sent != 0 {
+		t.Fatalf("expected the claimed message to be left to its relay, got %d sent: %v", sent, err)
+	}
+
+	if claimed, err := outbox.claim(messages[0]); err != nil || claimed {
+		t.Fatalf("expected a message to be claimed once, got %v", err)
+	}
+}
+
+func TestOutboxMarkSentFailure(t *testing.T) {
+	cluster := newFakeCluster()
+	outbox := testOutbox(t)
+	outbox.Lease = time.Millisecond
+	orders := cluster.service("orders").SetOutbox(outbox)
+
+	execTx(t, orders, &ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve"})
+
+	_, err := outbox.DB.Exec(`CREATE TRIGGER outbox_sent BEFORE UPDATE OF sent_at ON outbox BEGIN SELECT RAISE(FAIL, 'disk full'); END`)
+	if err != nil {
+		t.Fatalf("create trigger failed: %s", err.Error())
+	}
+
+	if _, err := orders.relayOutbox(); err == nil {
+		t.Fatalf("expected the markSent failure to be returned")
+	}
+
+	outbox.DB.Exec("DROP TRIGGER outbox_sent")
+	time.Sleep(10 * time.Millisecond)
+
+	// Once the lease is over the message is sent again, with the same dedup ID
+	if sent, err := orders.relayOutbox(); err != nil || sent != 1 {
+		t.Fatalf("expected the message to be sent again, got %d: %v", sent, err)
+	}
+
+	if actions := publishedActions(cluster); len(actions) != 2 {
+		t.Fatalf("expected the message to be published twice, got %v", actions)
+	}
+}
+
+func TestOutboxResponses(t *testing.T) {
+	cluster := newFakeCluster()
+	outbox := testOutbox(t)
+	timeline := NewMemoryTimelineStore()
+	sink := new(memoryAuditSink)
+	orders := cluster.service("orders").SetOutbox(outbox)
+	stock := cluster.service("stock").SetOutbox(outbox)
+	warehouse := cluster.service("warehouse").SetOutbox(outbox).SetTimelineStore(timeline).SetAudit(sink, AuditRedaction{})
+
+	handled := make(chan error, 1)
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		tx, err := outbox.DB.Begin()
+		if err != nil {
+			handled <- err
+			return err
+		}
+
+		if !request.NoResponse {
+			err = errors.New("expected the request to expect no response")
+		} else {
+			err = stock.RespondTx(tx, request, map[string]string{"reservation": "r-1"})
+		}
+
+		tx.Commit()
+		handled <- err
+		return err
+	})
+
+	execTx(t, orders, &ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve"})
+	if sent, err := orders.relayOutbox(); err != nil || sent != 1 {
+		t.Fatalf("expected the request to be sent, got %d: %v", sent, err)
+	}
+
+	if err := <-handled; err != nil {
+		t.Fatalf("handler failed: %s", err.Error())
+	}
+
+	if sent, err := stock.relayOutbox(); err != nil || sent != 0 {
+		t.Fatalf("expected no response to a request expecting none, got %d: %v", sent, err)
+	}
+
+	// A response to Exec is audited and recorded once relayed
+	actionID := "action-1"
+	request := &DataTransactionRequest{ID: "dt-2", Service: "warehouse", Action: "stock.reserve", IncomingService: "orders", ActionID: &actionID}
+	tx, _ := outbox.DB.Begin()
+	if err := warehouse.RespondTx(tx, request, map[string]string{"reservation": "r-2"}); err != nil {
+		t.Fatalf("RespondTx failed: %s", err.Error())
+	}
+	tx.Commit()
+
+	if events, _ := timeline.Events("dt-2"); len(events) != 0 || len(sink.records()) != 0 {
+		t.Fatalf("expected nothing recorded before the response is relayed")
+	}
+
+	if sent, err := warehouse.relayOutbox(); err != nil || sent != 1 {
+		t.Fatalf("expected the response to be sent, got %d: %v", sent, err)
+	}
+
+	events, _ := timeline.Events("dt-2")
+	if len(events) != 1 || events[0].Type != EventResponseSent || events[0].Peer != "orders" || events[0].ActionID != "action-1" {
+		t.Fatalf("expected the relayed response on the timeline, got %+v", events)
+	}
+
+	records := sink.records()
+	if len(records) != 1 || records[0].Event != AuditResponse || records[0].Service != "warehouse" || records[0].Caller != "orders" || !records[0].Success {
+		t.Fatalf("expected the relayed response to be audited, got %+v", records)
+	}
+}
+
+type memoryAuditSink struct {
+	lock    sync.Mutex
+	written []*AuditRecord
+}
+
+func (sink *memoryAuditSink) Write(record *AuditRecord) error {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	sink.written = append(sink.written, record)
+	return nil
+}
+
+func (sink *memoryAuditSink) records() []*AuditRecord {
+	sink.lock.Lock()
+	defer sink.lock.Unlock()
+	return append([]*AuditRecord{}, sink.written...)
+}
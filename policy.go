@@ -0,0 +1,315 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Effect is the outcome of a policy rule
+type Effect string
+
+// Policy effects
+const (
+	Allow Effect = "allow"
+	Deny  Effect = "deny"
+)
+
+// PolicyRule allows or denies services to call actions
+// Services match the IncomingService exactly or "*" for any service
+// Actions use the routing pattern syntax (users.*, admin.**) and "*" alone matches any action
+// Conditions map dot separated paths of the request Data to the values they must hold
+// Data is decoded with the codec of the request, when it can't be, deny rules with conditions match
+type PolicyRule struct {
+	Effect     Effect                 `json:"effect"`
+	Services   []string               `json:"services"`
+	Actions    []string               `json:"actions"`
+	Conditions map[string]interface{} `json:"conditions,omitempty"`
+}
+
+// Policy is a set of rules, deny rules override allow rules
+// Requests matching no rule get the Default effect, deny when empty
+type Policy struct {
+	Default Effect       `json:"default"`
+	Rules   []PolicyRule `json:"rules"`
+}
+
+func matchService(services []string, service string) bool {
+	for _, candidate := range services {
+		if candidate == "*" || candidate == service {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchAction(actions []string, action string) bool {
+	name, _, err := splitAction(action)
+	if err != nil {
+		return false
+	}
+
+	for _, candidate := range actions {
+		if candidate == "*" || candidate == action || candidate == name {
+			return true
+		}
+
+		if isPattern(candidate) && matchPattern(candidate, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dataPath resolves a dot separated path on JSON decoded data
+func dataPath(data interface{}, path string) (interface{}, bool) {
+	current := data
+	for _, key := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		if current, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// normalize turns a value into its JSON decoded form so it compares with request data
+func normalize(value interface{}) interface{} {
+	marshaled, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+
+	var normalized interface{}
+	if err := json.Unmarshal(marshaled, &normalized); err != nil {
+		return value
+	}
+
+	return normalized
+}
+
+func matchConditions(conditions map[string]interface{}, data interface{}) bool {
+	for path, expected := range conditions {
+		actual, ok := dataPath(data, path)
+		if !ok || !reflect.DeepEqual(actual, normalize(expected)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestData decodes the request Data once for the conditions of the rules
+type requestData struct {
+	request  *DataTransactionRequest
+	decoded  bool
+	data     interface{}
+	readable bool
+}
+
+func (rd *requestData) get() (interface{}, bool) {
+	if !rd.decoded {
+		rd.data, rd.readable = genericPayload(rd.request.ContentType, rd.request.Data)
+		rd.decoded = true
+	}
+
+	return rd.data, rd.readable
+}
+
+// matches reports if the rule applies to the request
+// Conditions on data that can't be decoded fail closed: deny rules match, allow rules don't
+func (rule *PolicyRule) matches(request *DataTransactionRequest, data *requestData) bool {
+	if !matchService(rule.Services, request.IncomingService) || !matchAction(rule.Actions, request.Action) {
+		return false
+	}
+
+	if len(rule.Conditions) == 0 {
+		return true
+	}
+
+	decoded, readable := data.get()
+	if !readable {
+		return rule.Effect == Deny
+	}
+
+	return matchConditions(rule.Conditions, decoded)
+}
+
+// Evaluate returns the effect of the policy on a request
+func (policy *Policy) Evaluate(request *DataTransactionRequest) Effect {
+	allowed := false
+	data := &requestData{request: request}
+
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		if !rule.matches(request, data) {
+			continue
+		}
+
+		if rule.Effect == Deny {
+			return Deny
+		}
+
+		allowed = allowed || rule.Effect == Allow
+	}
+
+	if allowed {
+		return Allow
+	}
+
+	if policy.Default == Allow {
+		return Allow
+	}
+
+	return Deny
+}
+
+func (policy *Policy) validate() error {
+	if policy.Default != "" && policy.Default != Allow && policy.Default != Deny {
+		return fmt.Errorf("invalid policy default effect %q", policy.Default)
+	}
+
+	for i, rule := range policy.Rules {
+		if rule.Effect != Allow && rule.Effect != Deny {
+			return fmt.Errorf("invalid effect %q on policy rule %d", rule.Effect, i)
+		}
+	}
+
+	return nil
+}
+
+// LoadPolicy reads a JSON policy file
+//
+//	{"default": "deny", "rules": [{"effect": "allow", "services": ["billing"], "actions": ["accounts.debit"]}]}
+func LoadPolicy(path string) (*Policy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := new(Policy)
+	if err := json.Unmarshal(content, policy); err != nil {
+		return nil, err
+	}
+
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// authorizer holds the policy in use, swapped atomically on reloads
+type authorizer struct {
+	lock   sync.RWMutex
+	policy *Policy
+}
+
+func (auth *authorizer) set(policy *Policy) {
+	auth.lock.Lock()
+	defer auth.lock.Unlock()
+	auth.policy = policy
+}
+
+func (auth *authorizer) get() *Policy {
+	auth.lock.RLock()
+	defer auth.lock.RUnlock()
+	return auth.policy
+}
+
+// SetPolicy sets the authorization policy checked before every action call, nil clears it
+func (gom *Gommunicator) SetPolicy(policy *Policy) *Gommunicator {
+	if policy == nil {
+		gom.authorizer.set(nil)
+		return gom
+	}
+
+	if err := policy.validate(); err != nil {
+		gom.onErr(err)
+		return gom
+	}
+
+	gom.authorizer.set(policy)
+	return gom
+}
+
+// SetPolicyFile loads the authorization policy from a file, reloading it when it changes
+// The file is checked every interval while the Gommunicator runs, a zero interval disables reloads
+func (gom *Gommunicator) SetPolicyFile(path string, interval time.Duration) error {
+	// Stat before reading, so a change made meanwhile is reloaded
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	policy, err := LoadPolicy(path)
+	if err != nil {
+		return err
+	}
+
+	gom.authorizer.set(policy)
+
+	if interval > 0 {
+		go gom.watchPolicyFile(path, interval, info.ModTime())
+	}
+
+	return nil
+}
+
+func (gom *Gommunicator) watchPolicyFile(path string, interval time.Duration, modTime time.Time) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gom.stop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				gom.onErr(err)
+				continue
+			}
+
+			if !info.ModTime().After(modTime) {
+				continue
+			}
+
+			modTime = info.ModTime()
+			policy, err := LoadPolicy(path)
+			if err != nil {
+				// Keep the previous policy until the file is fixed, reporting each broken version once
+				gom.onErr(fmt.Errorf("policy reload failed: %s", err.Error()))
+				continue
+			}
+
+			gom.authorizer.set(policy)
+			gom.tryLogInfo("Authorization policy reloaded", F("path", path))
+		}
+	}
+}
+
+// authorize checks the policy, a nil policy allows everything
+func (gom *Gommunicator) authorize(request *DataTransactionRequest) MapErr {
+	policy := gom.authorizer.get()
+	if policy == nil || policy.Evaluate(request) == Allow {
+		return nil
+	}
+
+	return NewSimpleError(
+		Forbidden,
+		fmt.Sprintf("%s is not allowed to call %s", request.IncomingService, request.Action),
+	)
+}
@@ -0,0 +1,138 @@
+package gommunicator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	policy := &Policy{
+		Rules: []PolicyRule{
+			{Effect: Allow, Services: []string{"billing"}, Actions: []string{"accounts.debit", "accounts.credit@v1"}},
+			{Effect: Allow, Services: []string{"*"}, Actions: []string{"accounts.read.*"}},
+			{Effect: Deny, Services: []string{"*"}, Actions: []string{"accounts.read.secrets"}},
+			{Effect: Allow, Services: []string{"support"}, Actions: []string{"accounts.close"}, Conditions: map[string]interface{}{"reason.code": 3}},
+		},
+	}
+
+	cases := []struct {
+		service string
+		action  string
+		data    interface{}
+		effect  Effect
+	}{
+		{"billing", "accounts.debit", nil, Allow},
+		{"billing", "accounts.debit@v2", nil, Allow}, // Unversioned rules match every version
+		{"billing", "accounts.credit@v1", nil, Allow},
+		{"billing", "accounts.credit@v2", nil, Deny},
+		{"billing", "accounts.credit", nil, Deny},
+		{"orders", "accounts.debit", nil, Deny},
+		{"orders", "accounts.read.balance", nil, Allow},
+		{"orders", "accounts.read.secrets", nil, Deny}, // Deny rules override allow rules
+		{"support", "accounts.close", map[string]interface{}{"reason": map[string]interface{}{"code": 3}}, Allow},
+		{"support", "accounts.close", map[string]interface{}{"reason": map[string]interface{}{"code": 4}}, Deny},
+		{"support", "accounts.close", nil, Deny},
+	}
+
+	for _, c := range cases {
+		request := &DataTransactionRequest{IncomingService: c.service, Action: c.action, Data: c.data}
+		if effect := policy.Evaluate(request); effect != c.effect {
+			t.Fatalf("expected %s calling %s to be %s, got %s", c.service, c.action, c.effect, effect)
+		}
+	}
+
+	policy.Default = Allow
+	if effect := policy.Evaluate(&DataTransactionRequest{IncomingService: "orders", Action: "orders.create"}); effect != Allow {
+		t.Fatalf("expected the default effect for unmatched requests, got %s", effect)
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := LoadPolicy(filepath.Join(dir, "missing.json")); err == nil {
+		t.Fatalf("expected an error for a missing file")
+	}
+
+	for name, content := range map[string]string{
+		"malformed.json": `{"rules": [`,
+		"default.json":   `{"default": "maybe"}`,
+		"effect.json":    `{"rules": [{"effect": "permit", "services": ["*"], "actions": ["*"]}]}`,
+	} {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(content), 0o600)
+
+		if policy, err := LoadPolicy(path); err == nil || policy != nil {
+			t.Fatalf("expected %s to be rejected without a policy, got %v", name, policy)
+		}
+	}
+}
+
+func TestPolicyReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy := func(content string, modTime time.Time) {
+		os.WriteFile(path, []byte(content), 0o600)
+		os.Chtimes(path, modTime, modTime)
+	}
+
+	start := time.Now().Add(-time.Minute)
+	writePolicy(`{"default": "allow"}`, start)
+
+	gom := NewGommunicator(nil, nil, nil, "", "accounts", "", "").SetLogState(false)
+	defer gom.Stop()
+
+	reloadErrors := make(chan error, 1)
+	gom.SetErrorHandler(func(err error) {
+		select {
+		case reloadErrors <- err:
+		default:
+		}
+	})
+
+	if err := gom.SetPolicyFile(path, 10*time.Millisecond); err != nil {
+		t.Fatalf("SetPolicyFile failed: %s", err.Error())
+	}
+
+	request := &DataTransactionRequest{IncomingService: "orders", Action: "accounts.debit"}
+	if gom.authorize(request) != nil {
+		t.Fatalf("expected the loaded policy to allow the request")
+	}
+
+	// A broken file keeps the previous policy
+	writePolicy(`{"default": "maybe"}`, start.Add(time.Second))
+	select {
+	case <-reloadErrors:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the reload failure to be reported")
+	}
+
+	if gom.authorize(request) != nil {
+		t.Fatalf("expected the previous policy to be kept")
+	}
+
+	writePolicy(`{"default": "deny"}`, start.Add(2*time.Second))
+	deadline := time.Now().Add(time.Second)
+	for gom.authorize(request) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the policy to be reloaded")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSetPolicyNil(t *testing.T) {
+	gom := NewGommunicator(nil, nil, nil, "", "accounts", "", "").SetLogState(false)
+	request := &DataTransactionRequest{IncomingService: "orders", Action: "accounts.debit"}
+
+	gom.SetPolicy(&Policy{Default: Deny})
+	if gom.authorize(request) == nil {
+		t.Fatalf("expected the policy to deny the request")
+	}
+
+	gom.SetPolicy(nil)
+	if denial := gom.authorize(request); denial != nil {
+		t.Fatalf("expected a cleared policy to allow everything, got %s", denial.Error())
+	}
+}
@@ -0,0 +1,236 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RedactTag marks struct fields redacted from the payloads logged and audited
+//
+//	type Card struct {
+//		Number string `json:"number" gommunicator:"redact"`
+//	}
+const RedactTag = "gommunicator"
+
+// Payloads are only redacted from what the library records, never from what is sent
+type redactor struct {
+	lock  sync.RWMutex
+	rules map[string][]string // Action name, "" for every action, to rules
+	types sync.Map            // reflect.Type to the rules of its redact tags
+}
+
+func newRedactor() *redactor {
+	return &redactor{rules: make(map[string][]string)}
+}
+
+var pathIndex = regexp.MustCompile(`\[[^\]]*\]`)
+
+// normalizeRule turns a JSON path rule ($.items[*].card.number) into a dotted rule anchored to the root
+// Rules without a dot are field names redacted at any depth
+func normalizeRule(rule string) string {
+	if strings.HasPrefix(rule, "$..") && !strings.Contains(rule[3:], ".") {
+		return pathIndex.ReplaceAllString(rule[3:], "")
+	}
+
+	anchored := strings.HasPrefix(rule, "$")
+	rule = pathIndex.ReplaceAllString(strings.TrimLeft(rule, "$."), "")
+	rule = strings.Replace(rule, "..", ".", -1)
+
+	if anchored || strings.Contains(rule, ".") {
+		return "$." + rule
+	}
+
+	return rule
+}
+
+// tagRules returns the root anchored rules of the fields tagged with RedactTag
+func tagRules(valueType reflect.Type, prefix string, visiting map[reflect.Type]bool) []string {
+	for valueType.Kind() == reflect.Ptr || valueType.Kind() == reflect.Slice || valueType.Kind() == reflect.Array || valueType.Kind() == reflect.Map {
+		valueType = valueType.Elem()
+	}
+
+	if valueType.Kind() != reflect.Struct || visiting[valueType] {
+		return nil
+	}
+
+	visiting[valueType] = true
+	defer delete(visiting, valueType)
+
+	var rules []string
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag := field.Tag.Get("json")
+		name := strings.SplitN(tag, ",", 2)[0]
+
+		if name == "-" && !strings.Contains(tag, ",") {
+			continue
+		}
+
+		if field.Anonymous && name == "" {
+			rules = append(rules, tagRules(field.Type, prefix, visiting)...)
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		if field.Tag.Get(RedactTag) == "redact" {
+			rules = append(rules, "$."+path)
+			continue
+		}
+
+		rules = append(rules, tagRules(field.Type, path, visiting)...)
+	}
+
+	return rules
+}
+
+func (redactor *redactor) typeRules(valueType reflect.Type) []string {
+	if cached, ok := redactor.types.Load(valueType); ok {
+		return cached.([]string)
+	}
+
+	rules := tagRules(valueType, "", make(map[reflect.Type]bool))
+	redactor.types.Store(valueType, rules)
+	return rules
+}
+
+func actionName(action string) string {
+	name, _, err := splitAction(action)
+	if err != nil {
+		return action
+	}
+
+	return name
+}
+
+func (redactor *redactor) add(action string, rules ...string) {
+	redactor.lock.Lock()
+	defer redactor.lock.Unlock()
+
+	action = actionName(action)
+	for _, rule := range rules {
+		redactor.rules[action] = append(redactor.rules[action], normalizeRule(rule))
+	}
+}
+
+func (redactor *redactor) actionRules(action string) []string {
+	redactor.lock.RLock()
+	defer redactor.lock.RUnlock()
+
+	rules := append([]string{}, redactor.rules[""]...)
+	return append(rules, redactor.rules[actionName(action)]...)
+}
+
+// RedactPaths redacts payload fields of an action, an empty action applies to every action
+// Rules are JSON paths from the payload root ($.card.number, items[*].token) or field names redacted at any depth (password)
+func (gom *Gommunicator) RedactPaths(action string, rules ...string) *Gommunicator {
+	gom.redactor.add(action, rules...)
+	return gom
+}
+
+// RedactType redacts the fields of prototype tagged with RedactTag from the payloads of an action
+// Outgoing Go values are redacted by their own tags, RedactType is needed for the payloads received
+func (gom *Gommunicator) RedactType(action string, prototype interface{}) *Gommunicator {
+	gom.redactor.add(action, gom.redactor.typeRules(reflect.TypeOf(prototype))...)
+	return gom
+}
+
+func redactedField(key, path string, rules []string) bool {
+	for _, rule := range rules {
+		if strings.HasPrefix(rule, "$.") {
+			if rule[2:] == path {
+				return true
+			}
+		} else if strings.EqualFold(rule, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// redactFields masks the fields of JSON like values, array items share the path of their array
+func redactFields(value interface{}, rules []string, path string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			itemPath := key
+			if path != "" {
+				itemPath = path + "." + key
+			}
+
+			if redactedField(key, itemPath, rules) {
+				redacted[key] = RedactedValue
+			} else {
+				redacted[key] = redactFields(item, rules, itemPath)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactFields(item, rules, path)
+		}
+		return redacted
+	}
+
+	return value
+}
+
+// redact returns a redacted JSON like copy of the payload of an action, to be logged or audited
+// Payloads that can't be read are fully redacted
+func (gom *Gommunicator) redact(action, contentType string, data interface{}, extra ...string) interface{} {
+	rules := append(gom.redactor.actionRules(action), extra...)
+
+	if data != nil && isJSON(contentType) {
+		rules = append(rules, gom.redactor.typeRules(reflect.TypeOf(data))...)
+	}
+
+	generic, ok := genericPayload(contentType, data)
+	if !ok {
+		return RedactedValue
+	}
+
+	return redactFields(generic, rules, "")
+}
+
+// redactedPayload is a payload redacted only when a log line is written
+type redactedPayload struct {
+	gom         *Gommunicator
+	action      string
+	contentType string
+	data        interface{}
+}
+
+func (payload redactedPayload) MarshalJSON() ([]byte, error) {
+	return json.Marshal(payload.gom.redact(payload.action, payload.contentType, payload.data))
+}
+
+func (payload redactedPayload) String() string {
+	raw, err := payload.MarshalJSON()
+	if err != nil {
+		return RedactedValue
+	}
+
+	return string(raw)
+}
+
+// payloadField returns the redacted payload log field
+func (gom *Gommunicator) payloadField(action, contentType string, data interface{}) Field {
+	return F("payload", redactedPayload{gom: gom, action: action, contentType: contentType, data: data})
+}
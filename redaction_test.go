@@ -0,0 +1,53 @@
+package gommunicator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+type redactedCard struct {
+	Number string `json:"number" gommunicator:"redact"`
+	Brand  string `json:"brand"`
+}
+
+type redactedOrder struct {
+	ID    string         `json:"id"`
+	Cards []redactedCard `json:"cards"`
+	Token string         `json:"token"`
+	Notes string         `json:"notes"`
+}
+
+func TestRedaction(t *testing.T) {
+	var out bytes.Buffer
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").
+		SetLogger(NewJSONLogger(&out, LevelDebug)).
+		RedactPaths("orders.create", "$.token").
+		RedactPaths("", "notes")
+
+	order := &redactedOrder{ID: "o-1", Cards: []redactedCard{{Number: "4111", Brand: "visa"}}, Token: "t0k3n", Notes: "call me"}
+	gom.tryLogDebug("payload", gom.payloadField("orders.create@v2", "", order))
+
+	logged := out.String()
+	for _, secret := range []string{"4111", "t0k3n", "call me"} {
+		if strings.Contains(logged, secret) {
+			t.Fatalf("%s leaked in %s", secret, logged)
+		}
+	}
+
+	if !strings.Contains(logged, "visa") || !strings.Contains(logged, "o-1") {
+		t.Fatalf("expected non sensitive fields to be kept, got %s", logged)
+	}
+
+	if order.Cards[0].Number != "4111" || order.Token != "t0k3n" {
+		t.Fatalf("redaction must not modify the payload")
+	}
+
+	// Received payloads are redacted by the registered types
+	gom.RedactType("orders.update", redactedOrder{})
+	received := map[string]interface{}{"cards": []interface{}{map[string]interface{}{"number": "4111"}}}
+	redacted := gom.redact("orders.update", "", received).(map[string]interface{})
+	if redacted["cards"].([]interface{})[0].(map[string]interface{})["number"] != RedactedValue {
+		t.Fatalf("expected the tagged field to be redacted, got %v", redacted)
+	}
+}
@@ -9,8 +9,7 @@ type MiddlewareFunc func(*Context) error
 // Apply applies middlewares and returns an ActionHandler
 func (gom *Gommunicator) Apply(handler MiddlewareFunc, middlewares ...MiddlewareFunc) ActionHandler {
 	return func(dt *DataTransactionRequest) error {
-		c := new(Context)
-		c.Request = dt
+		c := newContext(gom, dt)
 
 		for _, middleware := range middlewares {
 			if err := middleware(c); err != nil {
@@ -23,15 +22,19 @@ func (gom *Gommunicator) Apply(handler MiddlewareFunc, middlewares ...Middleware
 }
 
 // RegisterAction registers a callback for a new handler
+// The action may carry a version (orders.create@v2) and may be a pattern (users.*)
 func (gom *Gommunicator) RegisterAction(action string, handler ActionHandler) *Gommunicator {
-	gom.actions[action] = handler
+	if err := gom.actions.add(action, handler); err != nil {
+		gom.onErr(err)
+	}
 	return gom
 }
 
 // CallAction calls a registered callback
+// Versioned requests are served by the newest compatible version registered, never by an unversioned action
 func (gom *Gommunicator) CallAction(request *DataTransactionRequest) error {
-	if callback, ok := gom.actions[request.Action]; ok == true {
-		err := callback(request)
+	if rt, ok := gom.actions.lookup(request.Action); ok == true {
+		err := rt.handler(request)
 		if err != nil {
 			gom.onErr(err)
 			return err
@@ -0,0 +1,501 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// ErrNoLiveInstances is returned by Exec when the registry knows no live instance of the target service
+var ErrNoLiveInstances = errors.New("no live instances")
+
+// Instance describes a running Gommunicator consuming a service queue
+type Instance struct {
+	Service       string    `json:"service"`
+	InstanceID    string    `json:"instanceId"`
+	Actions       []string  `json:"actions"`
+	StartedAt     time.Time `json:"startedAt"`
+	LastHeartbeat time.Time `json:"lastHeartbeat"`
+	// HeartbeatInterval is the interval the instance sends its heartbeats at, instances may use different ones
+	HeartbeatInterval time.Duration `json:"heartbeatInterval,omitempty"`
+}
+
+// Alive reports if the instance sent a heartbeat within the ttl
+func (instance *Instance) Alive(ttl time.Duration) bool {
+	return time.Since(instance.LastHeartbeat) <= ttl
+}
+
+// live reports if the instance missed less than 3 of its heartbeats
+// Instances registered without their interval are judged by fallback
+func (instance *Instance) live(fallback time.Duration) bool {
+	if instance.HeartbeatInterval > 0 {
+		return instance.Alive(3 * instance.HeartbeatInterval)
+	}
+
+	return instance.Alive(fallback)
+}
+
+// Registry stores the instances of the services cluster
+type Registry interface {
+	// Register creates or refreshes an instance, it is called on Start and on every heartbeat
+	Register(instance *Instance) error
+	// Deregister removes an instance
+	Deregister(service, instanceID string) error
+	// Instances lists the known instances of a service
+	Instances(service string) ([]*Instance, error)
+	// All lists every known instance
+	All() ([]*Instance, error)
+}
+
+// MemoryRegistry is an in process Registry, useful for tests and single binary clusters
+type MemoryRegistry struct {
+	lock      sync.RWMutex
+	instances map[string]map[string]*Instance
+}
+
+// NewMemoryRegistry returns a new MemoryRegistry
+func NewMemoryRegistry() *MemoryRegistry {
+	return &MemoryRegistry{instances: make(map[string]map[string]*Instance)}
+}
+
+// Register creates or refreshes an instance
+func (registry *MemoryRegistry) Register(instance *Instance) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	if registry.instances[instance.Service] == nil {
+		registry.instances[instance.Service] = make(map[string]*Instance)
+	}
+
+	copied := *instance
+	registry.instances[instance.Service][instance.InstanceID] = &copied
+	return nil
+}
+
+// Deregister removes an instance
+func (registry *MemoryRegistry) Deregister(service, instanceID string) error {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	delete(registry.instances[service], instanceID)
+	return nil
+}
+
+// Instances lists the known instances of a service
+func (registry *MemoryRegistry) Instances(service string) ([]*Instance, error) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	instances := make([]*Instance, 0, len(registry.instances[service]))
+	for _, instance := range registry.instances[service] {
+		copied := *instance
+		instances = append(instances, &copied)
+	}
+
+	return instances, nil
+}
+
+// All lists every known instance
+func (registry *MemoryRegistry) All() ([]*Instance, error) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+
+	instances := make([]*Instance, 0)
+	for _, byID := range registry.instances {
+		for _, instance := range byID {
+			copied := *instance
+			instances = append(instances, &copied)
+		}
+	}
+
+	return instances, nil
+}
+
+const registryKeyPrefix = "registry#"
+
+// registryServicesKey is the item listing the services known by a DynamoRegistry
+const registryServicesKey = "registry-services"
+
+// DynamoRegistry is a Registry backed by a DynamoDB table with a single "id" hash key
+// Every service is an item mapping its instance IDs to their JSON, so lookups are single reads
+// Use a table of its own, instances are pruned once they missed their heartbeats for ttl:
+//
+//	gom.SetRegistry(NewDynamoRegistry(dynamo, "registry", time.Minute), 10*time.Second)
+type DynamoRegistry struct {
+	dynamo dynamodbiface.DynamoDBAPI
+	table  string
+	ttl    time.Duration
+}
+
+// NewDynamoRegistry returns a new DynamoRegistry
+// When ttl is set, stale instances are pruned and items carry an "expiresAt" attribute usable as the table TTL attribute
+func NewDynamoRegistry(dynamo dynamodbiface.DynamoDBAPI, table string, ttl time.Duration) *DynamoRegistry {
+	return &DynamoRegistry{
+		dynamo: dynamo,
+		table:  table,
+		ttl:    ttl,
+	}
+}
+
+func registryKey(service string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id": {
+			S: aws.String(registryKeyPrefix + service),
+		},
+	}
+}
+
+// instances reads the instances of a service item, nil when the item is missing
+func (registry *DynamoRegistry) instances(service string) (map[string]*Instance, error) {
+	output, err := registry.dynamo.GetItem(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key:            registryKey(service),
+		TableName:      aws.String(registry.table),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if output.Item == nil || output.Item["instances"] == nil {
+		return nil, nil
+	}
+
+	instances := make(map[string]*Instance, len(output.Item["instances"].M))
+	for instanceID, value := range output.Item["instances"].M {
+		instance := new(Instance)
+		if err := json.Unmarshal([]byte(aws.StringValue(value.S)), instance); err != nil {
+			return nil, err
+		}
+		instances[instanceID] = instance
+	}
+
+	return instances, nil
+}
+
+// Register creates or refreshes an instance, pruning the stale instances of its service
+func (registry *DynamoRegistry) Register(instance *Instance) error {
+	raw, err := json.Marshal(instance)
+	if err != nil {
+		return err
+	}
+
+	known, err := registry.instances(instance.Service)
+	if err != nil {
+		return err
+	}
+
+	names := map[string]*string{"#instance": aws.String(instance.InstanceID)}
+	values := map[string]*dynamodb.AttributeValue{":instance": {S: aws.String(string(raw))}}
+	update := "SET instances.#instance = :instance"
+	var condition *string
+
+	if known == nil {
+		update = "SET instances = :instances"
+		values = map[string]*dynamodb.AttributeValue{
+			":instances": {M: map[string]*dynamodb.AttributeValue{instance.InstanceID: {S: aws.String(string(raw))}}},
+		}
+		names = nil
+		condition = aws.String("attribute_not_exists(instances)")
+	}
+
+	if registry.ttl > 0 {
+		update += ", expiresAt = :expiresAt"
+		values[":expiresAt"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(instance.LastHeartbeat.Add(registry.ttl).Unix(), 10)),
+		}
+
+		stale := make([]string, 0)
+		for instanceID, other := range known {
+			if instanceID != instance.InstanceID && !other.Alive(registry.ttl) {
+				name := fmt.Sprintf("#stale%d", len(stale))
+				names[name] = aws.String(instanceID)
+				stale = append(stale, "instances."+name)
+			}
+		}
+
+		if len(stale) > 0 {
+			update += " REMOVE " + strings.Join(stale, ", ")
+		}
+	}
+
+	_, err = registry.dynamo.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(registry.table),
+		Key:                       registryKey(instance.Service),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       condition,
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+	})
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		// Another instance of the service created the item meanwhile
+		return registry.Register(instance)
+	}
+
+	if err != nil || known != nil {
+		return err
+	}
+
+	_, err = registry.dynamo.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                 aws.String(registry.table),
+		Key:                       map[string]*dynamodb.AttributeValue{"id": {S: aws.String(registryServicesKey)}},
+		UpdateExpression:          aws.String("ADD services :service"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":service": {SS: aws.StringSlice([]string{instance.Service})}},
+	})
+
+	return err
+}
+
+// Deregister removes an instance
+func (registry *DynamoRegistry) Deregister(service, instanceID string) error {
+	_, err := registry.dynamo.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName:                aws.String(registry.table),
+		Key:                      registryKey(service),
+		UpdateExpression:         aws.String("REMOVE instances.#instance"),
+		ConditionExpression:      aws.String("attribute_exists(instances)"),
+		ExpressionAttributeNames: map[string]*string{"#instance": aws.String(instanceID)},
+	})
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return nil
+	}
+
+	return err
+}
+
+// Instances lists the known instances of a service
+func (registry *DynamoRegistry) Instances(service string) ([]*Instance, error) {
+	known, err := registry.instances(service)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]*Instance, 0, len(known))
+	for _, instance := range known {
+		instances = append(instances, instance)
+	}
+
+	return instances, nil
+}
+
+// All lists every known instance
+func (registry *DynamoRegistry) All() ([]*Instance, error) {
+	output, err := registry.dynamo.GetItem(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String(registryServicesKey)}},
+		TableName:      aws.String(registry.table),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]*Instance, 0)
+	if output.Item == nil || output.Item["services"] == nil {
+		return instances, nil
+	}
+
+	for _, service := range aws.StringValueSlice(output.Item["services"].SS) {
+		byService, err := registry.Instances(service)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, byService...)
+	}
+
+	return instances, nil
+}
+
+// DefaultHeartbeatInterval is the heartbeat interval used when SetRegistry gets none
+const DefaultHeartbeatInterval = 10 * time.Second
+
+// SetRegistry enables the registry subsystem
+// The instance is registered on Start and refreshed every interval, it is considered dead after 3 missed heartbeats
+// Intervals lower or equal to zero mean DefaultHeartbeatInterval
+func (gom *Gommunicator) SetRegistry(registry Registry, interval time.Duration) *Gommunicator {
+	if interval <= 0 {
+		interval = DefaultHeartbeatInterval
+	}
+
+	gom.registry = registry
+	gom.heartbeatInterval = interval
+	return gom
+}
+
+// InstanceID returns the identifier of this Gommunicator instance
+func (gom *Gommunicator) InstanceID() string {
+	return gom.instanceID
+}
+
+func (gom *Gommunicator) heartbeatTTL() time.Duration {
+	return 3 * gom.heartbeatInterval
+}
+
+func (gom *Gommunicator) instance() *Instance {
+	actions := make([]string, 0)
+	for _, rt := range gom.actions.list() {
+		if !strings.HasPrefix(rt.name, reservedPrefix) {
+			actions = append(actions, rt.action)
+		}
+	}
+
+	sort.Strings(actions)
+
+	return &Instance{
+		Service:           gom.ServiceName,
+		InstanceID:        gom.instanceID,
+		Actions:           actions,
+		StartedAt:         gom.startedAt,
+		LastHeartbeat:     time.Now(),
+		HeartbeatInterval: gom.heartbeatInterval,
+	}
+}
+
+func (gom *Gommunicator) heartbeat() {
+	ticker := time.NewTicker(gom.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-gom.stop:
+			if err := gom.registry.Deregister(gom.ServiceName, gom.instanceID); err != nil {
+				gom.onErr(err)
+			}
+			return
+		case <-ticker.C:
+			if err := gom.registry.Register(gom.instance()); err != nil {
+				gom.onErr(err)
+			}
+		}
+	}
+}
+
+func (gom *Gommunicator) startRegistry() error {
+	if gom.registry == nil {
+		return nil
+	}
+
+	gom.startedAt = time.Now()
+	if err := gom.registry.Register(gom.instance()); err != nil {
+		return err
+	}
+
+	go gom.heartbeat()
+	return nil
+}
+
+// LiveInstances lists the instances of a service that sent a heartbeat recently
+func (gom *Gommunicator) LiveInstances(service string) ([]*Instance, error) {
+	if gom.registry == nil {
+		return nil, errors.New("registry not configured")
+	}
+
+	instances, err := gom.registry.Instances(service)
+	if err != nil {
+		return nil, err
+	}
+
+	live := make([]*Instance, 0, len(instances))
+	for _, instance := range instances {
+		if instance.live(gom.heartbeatTTL()) {
+			live = append(live, instance)
+		}
+	}
+
+	return live, nil
+}
+
+// Topology lists the live instances of the cluster grouped by service
+func (gom *Gommunicator) Topology() (map[string][]*Instance, error) {
+	if gom.registry == nil {
+		return nil, errors.New("registry not configured")
+	}
+
+	instances, err := gom.registry.All()
+	if err != nil {
+		return nil, err
+	}
+
+	topology := make(map[string][]*Instance)
+	for _, instance := range instances {
+		if instance.live(gom.heartbeatTTL()) {
+			topology[instance.Service] = append(topology[instance.Service], instance)
+		}
+	}
+
+	return topology, nil
+}
+
+// liveCache keeps the instances read from the registry per service for a heartbeat interval
+// Exec checks liveness on every call, it must not read the registry every time
+// Lookups without a live instance are not kept, so a service starting is found on the next call
+type liveCache struct {
+	lock     sync.Mutex
+	services map[string]*cachedInstances
+}
+
+type cachedInstances struct {
+	instances []*Instance
+	readAt    time.Time
+}
+
+func newLiveCache() *liveCache {
+	return &liveCache{services: make(map[string]*cachedInstances)}
+}
+
+// cachedInstances returns the instances of a service, read again once older than the heartbeat interval
+func (gom *Gommunicator) cachedInstances(service string) ([]*Instance, error) {
+	gom.live.lock.Lock()
+	cached, ok := gom.live.services[service]
+	gom.live.lock.Unlock()
+
+	if ok && time.Since(cached.readAt) < gom.heartbeatInterval {
+		return cached.instances, nil
+	}
+
+	instances, err := gom.registry.Instances(service)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, instance := range instances {
+		if instance.live(gom.heartbeatTTL()) {
+			gom.live.lock.Lock()
+			gom.live.services[service] = &cachedInstances{instances: instances, readAt: time.Now()}
+			gom.live.lock.Unlock()
+			break
+		}
+	}
+
+	return instances, nil
+}
+
+// checkLiveness fails fast when the registry knows no live instance of the service
+func (gom *Gommunicator) checkLiveness(service string) error {
+	if gom.registry == nil {
+		return nil
+	}
+
+	instances, err := gom.cachedInstances(service)
+	if err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		if instance.live(gom.heartbeatTTL()) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%s: %w", service, ErrNoLiveInstances)
+}
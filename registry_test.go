@@ -0,0 +1,61 @@
+package gommunicator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckLiveness(t *testing.T) {
+	registry := NewMemoryRegistry()
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false).SetRegistry(registry, 0)
+
+	if gom.heartbeatInterval != DefaultHeartbeatInterval {
+		t.Fatalf("expected the default heartbeat interval, got %s", gom.heartbeatInterval)
+	}
+
+	if err := gom.checkLiveness("stock"); !errors.Is(err, ErrNoLiveInstances) {
+		t.Fatalf("expected ErrNoLiveInstances, got %v", err)
+	}
+
+	registry.Register(&Instance{Service: "stock", InstanceID: "1", LastHeartbeat: time.Now()})
+
+	if err := gom.checkLiveness("stock"); err != nil {
+		t.Fatalf("expected lookups without live instances not to be cached, got %v", err)
+	}
+
+	registry.Deregister("stock", "1")
+
+	if err := gom.checkLiveness("stock"); err != nil {
+		t.Fatalf("expected the live instances to be cached for a heartbeat interval, got %v", err)
+	}
+
+	gom.live.services["stock"].readAt = time.Now().Add(-DefaultHeartbeatInterval)
+
+	if err := gom.checkLiveness("stock"); !errors.Is(err, ErrNoLiveInstances) {
+		t.Fatalf("expected the deregistered instance to be gone after the cache expired, got %v", err)
+	}
+}
+
+func TestInstanceHeartbeatInterval(t *testing.T) {
+	registry := NewMemoryRegistry()
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false).SetRegistry(registry, time.Second)
+
+	if instance := gom.instance(); instance.HeartbeatInterval != time.Second {
+		t.Fatalf("expected the instance to carry its heartbeat interval, got %s", instance.HeartbeatInterval)
+	}
+
+	// Judged by the caller's 3s TTL, the slow instance would be dead and the fast one alive
+	lastHeartbeat := time.Now().Add(-10 * time.Second)
+	registry.Register(&Instance{Service: "stock", InstanceID: "slow", LastHeartbeat: lastHeartbeat, HeartbeatInterval: time.Minute})
+	registry.Register(&Instance{Service: "payments", InstanceID: "fast", LastHeartbeat: time.Now().Add(-2 * time.Second), HeartbeatInterval: 500 * time.Millisecond})
+	registry.Register(&Instance{Service: "payments", InstanceID: "legacy", LastHeartbeat: lastHeartbeat})
+
+	if err := gom.checkLiveness("stock"); err != nil {
+		t.Fatalf("expected the instance alive within its own interval, got %v", err)
+	}
+
+	if err := gom.checkLiveness("payments"); !errors.Is(err, ErrNoLiveInstances) {
+		t.Fatalf("expected the instance dead after missing its own heartbeats, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+package gommunicator
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// RemoteError is the structured error carried by failed responses
+// It implements MapErr, so it can be answered again or inspected by callers
+type RemoteError struct {
+	Type    ErrType                `json:"type"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Context map[string]interface{} `json:"context,omitempty"`
+	Retry   bool                   `json:"retryable,omitempty"`
+}
+
+// NewRemoteError returns the RemoteError describing a MapErr
+func NewRemoteError(err MapErr) *RemoteError {
+	return &RemoteError{
+		Type:    err.GetType(),
+		Code:    err.GetCode(),
+		Message: err.GetMessage(),
+		Context: err.GetContext(),
+		Retry:   IsRetryable(err),
+	}
+}
+
+// Retryable reports if the service answering the error flagged it as retryable
+func (err *RemoteError) Retryable() bool {
+	return err.Retry
+}
+
+// GetMessage returns the remote error message
+func (err *RemoteError) GetMessage() string {
+	return err.Message
+}
+
+// GetCode returns the remote error code
+func (err *RemoteError) GetCode() string {
+	return err.Code
+}
+
+// GetType returns the remote error ErrType
+func (err *RemoteError) GetType() ErrType {
+	return err.Type
+}
+
+// GetContext returns the remote error context
+func (err *RemoteError) GetContext() map[string]interface{} {
+	if err.Context == nil {
+		return map[string]interface{}{}
+	}
+
+	return err.Context
+}
+
+func (err *RemoteError) Error() string {
+	return err.Message
+}
+
+// Is reports if the remote error has the same code as target when target is a MapErr
+// It allows errors.Is(err, someMapErr) across service boundaries
+func (err *RemoteError) Is(target error) bool {
+	var mapErr MapErr
+	if !errors.As(target, &mapErr) {
+		return false
+	}
+
+	return mapErr.GetCode() == err.Code
+}
+
+// Decode decodes a context value of the remote error into incoming, see Decode
+func (err *RemoteError) Decode(key string, incoming interface{}) error {
+	return decode(err.Context[key], incoming)
+}
+
+// RemoteErrorFactory rebuilds a Go error from a RemoteError
+type RemoteErrorFactory func(*RemoteError) error
+
+var remoteErrors = make(map[string]RemoteErrorFactory)
+var remoteErrorsLock sync.RWMutex
+
+// RegisterRemoteError registers the factory rebuilding the errors with code
+// Callers get the rebuilt error from DataTransactionResponse.Err, so errors.As works on it
+func RegisterRemoteError(code string, factory RemoteErrorFactory) {
+	remoteErrorsLock.Lock()
+	defer remoteErrorsLock.Unlock()
+	remoteErrors[code] = factory
+}
+
+func remoteErrorFactory(code string) (RemoteErrorFactory, bool) {
+	remoteErrorsLock.RLock()
+	defer remoteErrorsLock.RUnlock()
+	factory, ok := remoteErrors[code]
+	return factory, ok
+}
+
+func firstLine(message string) string {
+	return strings.SplitN(message, "\n", 2)[0]
+}
+
+func init() {
+	simpleError := func(remote *RemoteError) error {
+		return &SimpleError{
+			Code:    SimpleErrorCode(remote.Code),
+			Type:    remote.Type,
+			Message: remote.Message,
+		}
+	}
+
+	RegisterRemoteError(string(Basic), simpleError)
+	RegisterRemoteError(string(Forbidden), simpleError)
+
+	RegisterRemoteError(string(Validation), func(remote *RemoteError) error {
+		validationErr := &ValidationError{Message: remote.Message}
+		if err := remote.Decode("fields", &validationErr.Fields); err != nil {
+			return remote
+		}
+
+		// The message was sent with the field errors appended, keep only its first line
+		if len(validationErr.Fields) > 0 {
+			validationErr.Message = firstLine(remote.Message)
+		}
+
+		return validationErr
+	})
+}
+
+// Err returns the error of a failed response, nil when it succeeded
+// Registered error types are rebuilt, others are returned as *RemoteError
+func (dt *DataTransactionResponse) Err() error {
+	if dt.Success {
+		return nil
+	}
+
+	remote := dt.Error
+	if remote == nil {
+		// Responses from services not sending structured errors
+		remote = &RemoteError{
+			Type:    ErrType(dt.Title),
+			Message: dt.Message,
+		}
+	}
+
+	if factory, ok := remoteErrorFactory(remote.Code); ok {
+		return factory(remote)
+	}
+
+	return remote
+}
@@ -0,0 +1,75 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRemoteErrorRoundTrip(t *testing.T) {
+	sent := NewValidationError("invalid request", []FieldError{
+		{Field: "items[0].sku", Rule: "required", Message: "sku is required"},
+	})
+
+	marshaled, err := json.Marshal(NewDataTransaction("dt", "orders.create").FailFromMapErr(sent))
+	if err != nil {
+		t.Fatalf("marshal failed: %s", err.Error())
+	}
+
+	response := new(DataTransactionResponse)
+	if err := json.Unmarshal(marshaled, response); err != nil {
+		t.Fatalf("unmarshal failed: %s", err.Error())
+	}
+
+	received := response.Err()
+
+	var validationErr *ValidationError
+	if !errors.As(received, &validationErr) {
+		t.Fatalf("expected a *ValidationError, got %T", received)
+	}
+
+	if validationErr.Message != sent.Message || len(validationErr.Fields) != 1 || validationErr.Fields[0] != sent.Fields[0] {
+		t.Fatalf("unexpected rebuilt error: %+v", validationErr)
+	}
+
+	if !errors.Is(received, NewValidationError("", nil)) || errors.Is(received, NewSimpleError(Basic, "")) {
+		t.Fatalf("errors.Is does not compare codes")
+	}
+
+	if (&DataTransactionResponse{Success: true}).Err() != nil {
+		t.Fatalf("successful responses must not have errors")
+	}
+}
+
+func TestStandardErrorsRoundTrip(t *testing.T) {
+	cause := errors.New("connection refused")
+	sent := NewRateLimitedError(1500*time.Millisecond, cause)
+
+	if !errors.Is(sent, cause) || !IsRetryable(sent) {
+		t.Fatalf("expected the cause to be wrapped and the error to be retryable")
+	}
+
+	if IsRetryable(NewNotFoundError("order", "42", nil)) {
+		t.Fatalf("not found errors must not be retryable")
+	}
+
+	marshaled, err := json.Marshal(NewDataTransaction("dt", "orders.create").FailFromMapErr(sent))
+	if err != nil {
+		t.Fatalf("marshal failed: %s", err.Error())
+	}
+
+	response := new(DataTransactionResponse)
+	if err := json.Unmarshal(marshaled, response); err != nil {
+		t.Fatalf("unmarshal failed: %s", err.Error())
+	}
+
+	if !response.Error.Retryable() {
+		t.Fatalf("expected the remote error to carry the retryable flag")
+	}
+
+	var rateLimited *RateLimitedError
+	if !errors.As(response.Err(), &rateLimited) || rateLimited.RetryAfter != sent.RetryAfter {
+		t.Fatalf("unexpected rebuilt error: %+v", response.Err())
+	}
+}
@@ -0,0 +1,272 @@
+package gommunicator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	actionSeparator  = "."
+	versionSeparator = "@"
+	wildcardSegment  = "*"
+	wildcardRest     = "**"
+)
+
+// actionVersion represents the version part of an action name (orders.create@v2.1)
+type actionVersion struct {
+	major int
+	minor int
+	set   bool
+}
+
+func (v actionVersion) String() string {
+	if !v.set {
+		return ""
+	}
+
+	return fmt.Sprintf("v%d.%d", v.major, v.minor)
+}
+
+func (v actionVersion) newerThan(other actionVersion) bool {
+	if v.major != other.major {
+		return v.major > other.major
+	}
+
+	return v.minor > other.minor
+}
+
+// compatibleWith reports if v can serve a request for the wanted version
+// A version is compatible when it shares the same major and has at least the same minor
+func (v actionVersion) compatibleWith(wanted actionVersion) bool {
+	if !wanted.set {
+		return true
+	}
+
+	return v.set && v.major == wanted.major && v.minor >= wanted.minor
+}
+
+func parseVersion(input string) (actionVersion, error) {
+	raw := strings.TrimPrefix(strings.ToLower(input), "v")
+	parts := strings.SplitN(raw, ".", 2)
+
+	major, err := strconv.Atoi(parts[0])
+	if err != nil || major < 0 {
+		return actionVersion{}, fmt.Errorf("invalid action version %q", input)
+	}
+
+	minor := 0
+	if len(parts) == 2 {
+		minor, err = strconv.Atoi(parts[1])
+		if err != nil || minor < 0 {
+			return actionVersion{}, fmt.Errorf("invalid action version %q", input)
+		}
+	}
+
+	return actionVersion{major: major, minor: minor, set: true}, nil
+}
+
+// splitAction splits an action into its name and version (orders.create@v2 -> orders.create, v2)
+func splitAction(action string) (string, actionVersion, error) {
+	idx := strings.LastIndex(action, versionSeparator)
+	if idx < 0 {
+		return action, actionVersion{}, nil
+	}
+
+	version, err := parseVersion(action[idx+1:])
+	if err != nil {
+		return "", actionVersion{}, err
+	}
+
+	return action[:idx], version, nil
+}
+
+func isPattern(name string) bool {
+	for _, segment := range strings.Split(name, actionSeparator) {
+		if segment == wildcardSegment || segment == wildcardRest {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPattern matches an action name against a pattern
+// "*" matches exactly one segment and a trailing "**" matches one or more segments
+func matchPattern(pattern, name string) bool {
+	patternSegments := strings.Split(pattern, actionSeparator)
+	nameSegments := strings.Split(name, actionSeparator)
+
+	for i, segment := range patternSegments {
+		if segment == wildcardRest && i == len(patternSegments)-1 {
+			return len(nameSegments) > i
+		}
+
+		if i >= len(nameSegments) {
+			return false
+		}
+
+		if segment != wildcardSegment && segment != nameSegments[i] {
+			return false
+		}
+	}
+
+	return len(patternSegments) == len(nameSegments)
+}
+
+type route struct {
+	action  string
+	name    string
+	version actionVersion
+	handler ActionHandler
+}
+
+// router resolves incoming actions to registered handlers
+type router struct {
+	lock     sync.RWMutex
+	exact    map[string][]*route
+	patterns []*route
+}
+
+func newRouter() *router {
+	return &router{
+		exact:    make(map[string][]*route),
+		patterns: make([]*route, 0),
+	}
+}
+
+func (r *router) add(action string, handler ActionHandler) error {
+	name, version, err := splitAction(action)
+	if err != nil {
+		return err
+	}
+
+	rt := &route{
+		action:  action,
+		name:    name,
+		version: version,
+		handler: handler,
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if isPattern(name) {
+		for i, registered := range r.patterns {
+			if registered.action == action {
+				r.patterns[i] = rt
+				return nil
+			}
+		}
+
+		r.patterns = append(r.patterns, rt)
+		return nil
+	}
+
+	routes := r.exact[name]
+	for i, registered := range routes {
+		if registered.version == version {
+			routes[i] = rt
+			return nil
+		}
+	}
+
+	routes = append(routes, rt)
+
+	// Keep versions sorted from the newest to the oldest so negotiation picks the first compatible
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].version.newerThan(routes[j].version)
+	})
+
+	r.exact[name] = routes
+	return nil
+}
+
+// lookup finds the route serving an action
+// Exact names win over patterns and, among versions, the newest compatible one is picked
+// Patterns serve the names without a compatible exact version
+// Unversioned routes only serve unversioned requests, a request for a version asks for a known contract
+func (r *router) lookup(action string) (*route, bool) {
+	name, version, err := splitAction(action)
+	if err != nil {
+		return nil, false
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, rt := range r.exact[name] {
+		if rt.version.compatibleWith(version) {
+			return rt, true
+		}
+	}
+
+	for _, rt := range r.patterns {
+		if matchPattern(rt.name, name) && rt.version.compatibleWith(version) {
+			return rt, true
+		}
+	}
+
+	return nil, false
+}
+
+// Group is a set of actions sharing a name prefix and middlewares
+type Group struct {
+	gom         *Gommunicator
+	prefix      string
+	middlewares []MiddlewareFunc
+}
+
+// Group returns a new route group, every action registered through it is prefixed with prefix
+// Ex.
+//
+//	users := gom.Group("users.", authMiddleware)
+//	users.Handle("create", createUser)   // users.create
+//	users.Handle("*", fallback)          // users.*
+func (gom *Gommunicator) Group(prefix string, middlewares ...MiddlewareFunc) *Group {
+	return &Group{
+		gom:         gom,
+		prefix:      prefix,
+		middlewares: middlewares,
+	}
+}
+
+// Group returns a nested route group inheriting the prefix and middlewares of its parent
+func (group *Group) Group(prefix string, middlewares ...MiddlewareFunc) *Group {
+	return &Group{
+		gom:         group.gom,
+		prefix:      group.prefix + prefix,
+		middlewares: append(group.chain(), middlewares...),
+	}
+}
+
+// Use appends middlewares to the group
+// Only actions registered after the call are affected
+func (group *Group) Use(middlewares ...MiddlewareFunc) *Group {
+	group.middlewares = append(group.middlewares, middlewares...)
+	return group
+}
+
+// Handle registers a handler for the action under the group prefix
+func (group *Group) Handle(action string, handler MiddlewareFunc, middlewares ...MiddlewareFunc) *Group {
+	group.gom.RegisterAction(
+		group.prefix+action,
+		group.gom.Apply(handler, append(group.chain(), middlewares...)...),
+	)
+	return group
+}
+
+// RegisterAction registers an ActionHandler under the group prefix running the group middlewares first
+func (group *Group) RegisterAction(action string, handler ActionHandler) *Group {
+	return group.Handle(action, func(c *Context) error {
+		return handler(c.Request)
+	})
+}
+
+func (group *Group) chain() []MiddlewareFunc {
+	chain := make([]MiddlewareFunc, len(group.middlewares))
+	copy(chain, group.middlewares)
+	return chain
+}
@@ -0,0 +1,85 @@
+package gommunicator
+
+import "testing"
+
+func fRoute(t *testing.T, action, expected string, rt *route) {
+	got := "<none>"
+	if rt != nil {
+		got = rt.action
+	}
+	t.Fatalf("lookup(%s) failed, expected %s got %s", action, expected, got)
+}
+
+func TestRouterLookup(t *testing.T) {
+	r := newRouter()
+	noop := func(*DataTransactionRequest) error { return nil }
+
+	for _, action := range []string{
+		"orders.create@v1",
+		"orders.create@v2",
+		"orders.create@v2.1",
+		"users.*",
+		"users.get",
+		"admin.**",
+		"orders.*@v3",
+		"billing.invoice",
+	} {
+		if err := r.add(action, noop); err != nil {
+			t.Fatalf("add(%s) failed: %s", action, err.Error())
+		}
+	}
+
+	cases := map[string]string{
+		"orders.create":      "orders.create@v2.1",
+		"orders.create@v1":   "orders.create@v1",
+		"orders.create@v2":   "orders.create@v2.1",
+		"orders.create@v2.1": "orders.create@v2.1",
+		"users.get":          "users.get",
+		"users.delete":       "users.*",
+		"admin.users.purge":  "admin.**",
+		"orders.create@v3":   "orders.*@v3", // No compatible exact version, the pattern serves it
+		"orders.create@v2.2": "",
+		"billing.invoice":    "billing.invoice",
+		"billing.invoice@v1": "", // Unversioned actions don't serve versioned requests
+		"users.roles.list":   "",
+		"admin":              "",
+	}
+
+	for action, expected := range cases {
+		rt, ok := r.lookup(action)
+		if expected == "" {
+			if ok {
+				fRoute(t, action, "<none>", rt)
+			}
+			continue
+		}
+
+		if !ok || rt.action != expected {
+			fRoute(t, action, expected, rt)
+		}
+	}
+}
+
+func TestGroupPrefixAndMiddlewares(t *testing.T) {
+	gom := &Gommunicator{actions: newRouter(), errorHandler: func(error) {}}
+	calls := make([]string, 0)
+
+	mark := func(name string) MiddlewareFunc {
+		return func(*Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	users := gom.Group("users.", mark("users"))
+	admin := users.Group("admin.", mark("admin"))
+	admin.Handle("purge", mark("handler"))
+
+	if err := gom.CallAction(&DataTransactionRequest{Action: "users.admin.purge"}); err != nil {
+		t.Fatalf("CallAction failed: %s", err.Error())
+	}
+
+	if len(calls) != 3 || calls[0] != "users" || calls[1] != "admin" || calls[2] != "handler" {
+		t.Fatalf("unexpected middleware chain: %v", calls)
+	}
+}
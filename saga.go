@@ -0,0 +1,343 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SagaStatus is the progress of a saga
+type SagaStatus string
+
+// Saga statuses
+const (
+	SagaRunning      SagaStatus = "RUNNING"
+	SagaCompleted    SagaStatus = "COMPLETED"
+	SagaCompensating SagaStatus = "COMPENSATING"
+	SagaCompensated  SagaStatus = "COMPENSATED"
+	SagaFailed       SagaStatus = "FAILED" // A compensation failed, the saga needs a manual intervention
+)
+
+// ErrSagaNotFound is returned when a saga definition or state is unknown
+var ErrSagaNotFound = errors.New("saga not found")
+
+// ErrSagaConflict is returned when a saga state was saved meanwhile by another run, which now owns the saga
+var ErrSagaConflict = errors.New("saga saved by another run")
+
+// SagaStep is a step of a saga: an action executed on a service, undone by its compensating action
+// Payload builds the request from the saga state, the saga input is sent when nil
+// CompensatePayload builds the compensation request, the step result is sent when nil, the saga input for timed out steps
+// Steps and compensations may run more than once after a crash, so their actions must be idempotent
+type SagaStep struct {
+	Name              string
+	Service           string
+	Action            string
+	Payload           func(state *SagaState) (interface{}, error)
+	CompensateService string // Defaults to Service
+	CompensateAction  string // Steps without compensation are not undone
+	CompensatePayload func(state *SagaState) (interface{}, error)
+	Timeout           int
+}
+
+// Saga is an ordered list of steps run as a single data transaction
+type Saga struct {
+	Name  string
+	Steps []*SagaStep
+	// CompensationRetries is the number of attempts of each compensation before the saga fails, 3 by default
+	CompensationRetries int
+}
+
+// SagaState is the persisted progress of a saga, keyed by its DataTransactionID
+// Step is the number of completed steps, while compensating the number of steps left to undo
+// Version counts the saves of the state, a run saving an outdated version stops with ErrSagaConflict
+type SagaState struct {
+	DataTransactionID string                     `json:"dataTransactionId"`
+	Saga              string                     `json:"saga"`
+	Status            SagaStatus                 `json:"status"`
+	Input             json.RawMessage            `json:"input,omitempty"`
+	Step              int                        `json:"step"`
+	Results           map[string]json.RawMessage `json:"results,omitempty"` // Step name to its response data as JSON
+	Error             string                     `json:"error,omitempty"`
+	StartedAt         time.Time                  `json:"startedAt"`
+	UpdatedAt         time.Time                  `json:"updatedAt"`
+	Version           int                        `json:"version"`
+}
+
+// Decode decodes the saga input into incoming
+func (state *SagaState) Decode(incoming interface{}) error {
+	return decode(state.Input, incoming)
+}
+
+// Result decodes the response data of a completed step into incoming
+func (state *SagaState) Result(step string, incoming interface{}) error {
+	result, ok := state.Results[step]
+	if !ok {
+		return fmt.Errorf("saga step %s has no result", step)
+	}
+
+	return decode(result, incoming)
+}
+
+type sagaRegistry struct {
+	lock  sync.RWMutex
+	sagas map[string]*Saga
+	store SagaStore
+}
+
+func newSagaRegistry() *sagaRegistry {
+	return &sagaRegistry{
+		sagas: make(map[string]*Saga),
+		store: NewMemorySagaStore(),
+	}
+}
+
+func (registry *sagaRegistry) get(name string) (*Saga, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	saga, ok := registry.sagas[name]
+	return saga, ok
+}
+
+// RegisterSaga registers a saga definition, so it can be run and resumed
+func (gom *Gommunicator) RegisterSaga(saga *Saga) *Gommunicator {
+	gom.sagas.lock.Lock()
+	defer gom.sagas.lock.Unlock()
+	gom.sagas.sagas[saga.Name] = saga
+	return gom
+}
+
+// SetSagaStore sets where saga states are persisted, in memory by default
+func (gom *Gommunicator) SetSagaStore(store SagaStore) *Gommunicator {
+	gom.sagas.store = store
+	return gom
+}
+
+// SagaState returns the persisted state of the saga run as dtID
+func (gom *Gommunicator) SagaState(dtID string) (*SagaState, error) {
+	state, err := gom.sagas.store.Load(dtID)
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil {
+		return nil, ErrSagaNotFound
+	}
+
+	return state, nil
+}
+
+// RunSaga runs a registered saga as the data transaction dtID and waits for it
+// A failed step compensates the completed ones in reverse, the returned state tells how it ended
+// A step timing out is compensated as well, its action may have been applied without a response
+func (gom *Gommunicator) RunSaga(name, dtID string, input interface{}) (*SagaState, error) {
+	saga, ok := gom.sagas.get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrSagaNotFound, name)
+	}
+
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	state := &SagaState{
+		DataTransactionID: dtID,
+		Saga:              name,
+		Status:            SagaRunning,
+		Input:             raw,
+		Results:           make(map[string]json.RawMessage),
+		StartedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	if err := gom.sagas.store.Save(state); err != nil {
+		return nil, err
+	}
+
+	return state, gom.continueSaga(saga, state)
+}
+
+// ResumeSagas continues the running and compensating sagas not updated for olderThan
+// Call it on start up to finish the sagas of a crashed instance
+// Every saga is claimed by saving it first, sagas claimed meanwhile by another instance are skipped
+func (gom *Gommunicator) ResumeSagas(olderThan time.Duration) error {
+	states, err := gom.sagas.store.Unfinished()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, state := range states {
+		if time.Since(state.UpdatedAt) < olderThan {
+			continue
+		}
+
+		saga, ok := gom.sagas.get(state.Saga)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrSagaNotFound, state.Saga))
+			continue
+		}
+
+		fields := []Field{F(FieldDataTransactionID, state.DataTransactionID), F("saga", state.Saga), F("status", state.Status)}
+		err := gom.saveSaga(state)
+		if err == nil {
+			gom.tryLogInfo("Saga resumed", fields...)
+			err = gom.continueSaga(saga, state)
+		}
+
+		if errors.Is(err, ErrSagaConflict) {
+			gom.tryLogInfo("Saga skipped, another run owns it", fields...)
+			continue
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (gom *Gommunicator) saveSaga(state *SagaState) error {
+	state.UpdatedAt = time.Now()
+	return gom.sagas.store.Save(state)
+}
+
+// continueSaga runs the saga from its persisted state, errors are persistence errors
+// ErrSagaConflict stops the run, another run took the saga over
+func (gom *Gommunicator) continueSaga(saga *Saga, state *SagaState) error {
+	if state.Results == nil {
+		state.Results = make(map[string]json.RawMessage)
+	}
+
+	for state.Status == SagaRunning && state.Step < len(saga.Steps) {
+		step := saga.Steps[state.Step]
+
+		result, err := gom.runSagaStep(state, step.Service, step.Action, step.Timeout, step.Payload, nil)
+		if err != nil {
+			gom.tryLogErr("Saga step failed", F(FieldDataTransactionID, state.DataTransactionID), F("saga", saga.Name), F("step", step.Name), errorField(err))
+			state.Status = SagaCompensating
+			state.Error = fmt.Sprintf("step %s: %s", step.Name, err.Error())
+
+			// Without a response the step may still have been applied, so it is compensated too
+			var timeoutErr *TimeoutError
+			if errors.As(err, &timeoutErr) && step.CompensateAction != "" {
+				state.Step++
+			}
+		} else {
+			state.Results[step.Name] = result
+			state.Step++
+		}
+
+		if err := gom.saveSaga(state); err != nil {
+			return err
+		}
+	}
+
+	if state.Status == SagaRunning {
+		state.Status = SagaCompleted
+		return gom.saveSaga(state)
+	}
+
+	retries := saga.CompensationRetries
+	if retries <= 0 {
+		retries = 3
+	}
+
+	for state.Status == SagaCompensating && state.Step > 0 {
+		step := saga.Steps[state.Step-1]
+
+		if step.CompensateAction != "" {
+			service := step.CompensateService
+			if service == "" {
+				service = step.Service
+			}
+
+			var err error
+			for attempt := 0; attempt < retries; attempt++ {
+				if attempt > 0 {
+					time.Sleep(time.Duration(attempt) * time.Second)
+				}
+
+				if _, err = gom.runSagaStep(state, service, step.CompensateAction, step.Timeout, step.CompensatePayload, state.Results[step.Name]); err == nil {
+					break
+				}
+			}
+
+			if err != nil {
+				gom.tryLogErr("Saga compensation failed", F(FieldDataTransactionID, state.DataTransactionID), F("saga", saga.Name), F("step", step.Name), errorField(err))
+				state.Status = SagaFailed
+				state.Error = fmt.Sprintf("%s; compensation of %s: %s", state.Error, step.Name, err.Error())
+				return gom.saveSaga(state)
+			}
+		}
+
+		state.Step--
+		if err := gom.saveSaga(state); err != nil {
+			return err
+		}
+	}
+
+	if state.Status == SagaCompensating {
+		state.Status = SagaCompensated
+		return gom.saveSaga(state)
+	}
+
+	return nil
+}
+
+// runSagaStep executes an action of the saga and waits for its response data
+func (gom *Gommunicator) runSagaStep(state *SagaState, service, action string, timeout int, payload func(*SagaState) (interface{}, error), fallback json.RawMessage) (json.RawMessage, error) {
+	var data interface{} = state.Input
+	if fallback != nil {
+		data = fallback
+	}
+
+	if payload != nil {
+		built, err := payload(state)
+		if err != nil {
+			return nil, err
+		}
+		data = built
+	}
+
+	return gom.execAndWait(state.DataTransactionID, service, action, timeout, data)
+}
+
+// execAndWait executes an action and waits for its response data as JSON
+// Failed responses return their error, missing ones a TimeoutError
+func (gom *Gommunicator) execAndWait(dtID, service, action string, timeout int, payload interface{}) (json.RawMessage, error) {
+	receiver, err := gom.Exec(&ExecInput{
+		DataTransactionID: dtID,
+		Service:           service,
+		Action:            action,
+		Payload:           payload,
+		Timeout:           timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response := <-receiver
+	if response == nil {
+		if timeout == 0 {
+			timeout = 5
+		}
+		return nil, NewTimeoutError(service, action, time.Duration(timeout)*time.Second, nil)
+	}
+
+	if err := response.Err(); err != nil {
+		return nil, err
+	}
+
+	// Data of non JSON codecs is decoded, results are JSON whatever the codec of the action
+	var data interface{}
+	if err := response.Decode(&data); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(data)
+}
@@ -0,0 +1,231 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// SagaStore persists saga states by DataTransactionID
+type SagaStore interface {
+	// Save stores the state when its Version is the stored one, then increments it
+	// A mismatch returns ErrSagaConflict, a new state has the Version 0
+	Save(state *SagaState) error
+	// Load returns nil when the saga is unknown
+	Load(dtID string) (*SagaState, error)
+	// Unfinished lists the running and compensating sagas
+	Unfinished() ([]*SagaState, error)
+}
+
+func unfinishedSaga(status SagaStatus) bool {
+	return status == SagaRunning || status == SagaCompensating
+}
+
+func copySagaState(state *SagaState) *SagaState {
+	copied := *state
+	copied.Results = make(map[string]json.RawMessage, len(state.Results))
+	for step, result := range state.Results {
+		copied.Results[step] = result
+	}
+	return &copied
+}
+
+// MemorySagaStore is an in process SagaStore, sagas can't be resumed after a crash with it
+type MemorySagaStore struct {
+	lock   sync.RWMutex
+	states map[string]*SagaState
+}
+
+// NewMemorySagaStore returns a new MemorySagaStore
+func NewMemorySagaStore() *MemorySagaStore {
+	return &MemorySagaStore{states: make(map[string]*SagaState)}
+}
+
+// Save stores a copy of the state
+func (store *MemorySagaStore) Save(state *SagaState) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	version := 0
+	if stored, ok := store.states[state.DataTransactionID]; ok {
+		version = stored.Version
+	}
+
+	if state.Version != version {
+		return ErrSagaConflict
+	}
+
+	state.Version++
+	store.states[state.DataTransactionID] = copySagaState(state)
+	return nil
+}
+
+// Load returns a copy of the state of a saga
+func (store *MemorySagaStore) Load(dtID string) (*SagaState, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	state, ok := store.states[dtID]
+	if !ok {
+		return nil, nil
+	}
+
+	return copySagaState(state), nil
+}
+
+// Unfinished lists the running and compensating sagas
+func (store *MemorySagaStore) Unfinished() ([]*SagaState, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	states := make([]*SagaState, 0)
+	for _, state := range store.states {
+		if unfinishedSaga(state.Status) {
+			states = append(states, copySagaState(state))
+		}
+	}
+
+	return states, nil
+}
+
+const sagaKeyPrefix = "saga#"
+
+// DynamoSagaStore is a SagaStore backed by a DynamoDB table with a single "id" hash key
+// Unfinished scans the table, so give sagas a table of their own rather than the dedup table,
+// which grows with every message:
+//
+//	gom.SetSagaStore(NewDynamoSagaStore(dynamo, "sagas"))
+type DynamoSagaStore struct {
+	dynamo dynamodbiface.DynamoDBAPI
+	table  string
+}
+
+// NewDynamoSagaStore returns a new DynamoSagaStore
+func NewDynamoSagaStore(dynamo dynamodbiface.DynamoDBAPI, table string) *DynamoSagaStore {
+	return &DynamoSagaStore{
+		dynamo: dynamo,
+		table:  table,
+	}
+}
+
+// Save stores the state, conditioned on its version
+func (store *DynamoSagaStore) Save(state *SagaState) error {
+	saved := copySagaState(state)
+	saved.Version++
+
+	raw, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+
+	condition := aws.String("attribute_not_exists(id)")
+	values := map[string]*dynamodb.AttributeValue(nil)
+	if state.Version > 0 {
+		condition = aws.String("version = :version")
+		values = map[string]*dynamodb.AttributeValue{
+			":version": {N: aws.String(strconv.Itoa(state.Version))},
+		}
+	}
+
+	_, err = store.dynamo.PutItem(&dynamodb.PutItemInput{
+		ConditionExpression:       condition,
+		ExpressionAttributeValues: values,
+		Item: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(sagaKeyPrefix + state.DataTransactionID),
+			},
+			"status": {
+				S: aws.String(string(state.Status)),
+			},
+			"state": {
+				S: aws.String(string(raw)),
+			},
+			"version": {
+				N: aws.String(strconv.Itoa(saved.Version)),
+			},
+		},
+		TableName: aws.String(store.table),
+	})
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return ErrSagaConflict
+	}
+
+	if err != nil {
+		return err
+	}
+
+	state.Version = saved.Version
+	return nil
+}
+
+func sagaFromItem(item map[string]*dynamodb.AttributeValue) (*SagaState, error) {
+	if item == nil || item["state"] == nil {
+		return nil, nil
+	}
+
+	state := new(SagaState)
+	return state, json.Unmarshal([]byte(aws.StringValue(item["state"].S)), state)
+}
+
+// Load returns the state of a saga
+func (store *DynamoSagaStore) Load(dtID string) (*SagaState, error) {
+	output, err := store.dynamo.GetItem(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(sagaKeyPrefix + dtID),
+			},
+		},
+		TableName: aws.String(store.table),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sagaFromItem(output.Item)
+}
+
+// Unfinished lists the running and compensating sagas
+func (store *DynamoSagaStore) Unfinished() ([]*SagaState, error) {
+	input := &dynamodb.ScanInput{
+		TableName:                aws.String(store.table),
+		FilterExpression:         aws.String("begins_with(id, :prefix) and (#status = :running or #status = :compensating)"),
+		ExpressionAttributeNames: map[string]*string{"#status": aws.String("status")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":prefix":       {S: aws.String(sagaKeyPrefix)},
+			":running":      {S: aws.String(string(SagaRunning))},
+			":compensating": {S: aws.String(string(SagaCompensating))},
+		},
+	}
+
+	states := make([]*SagaState, 0)
+	var decodeErr error
+	err := store.dynamo.ScanPages(input, func(page *dynamodb.ScanOutput, lastPage bool) bool {
+		for _, item := range page.Items {
+			state, err := sagaFromItem(item)
+			if err != nil {
+				decodeErr = err
+				return false
+			}
+
+			if state != nil {
+				states = append(states, state)
+			}
+		}
+		return true
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return states, decodeErr
+}
@@ -0,0 +1,244 @@
+package gommunicator
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResumeSagas(t *testing.T) {
+	store := NewMemorySagaStore()
+	gom := NewGommunicator(nil, nil, nil, "", "checkout", "", "").SetLogState(false).SetSagaStore(store)
+
+	gom.RegisterSaga(&Saga{
+		Name: "checkout",
+		Steps: []*SagaStep{
+			{
+				Name:    "reserve",
+				Service: "stock",
+				Action:  "stock.reserve",
+				Payload: func(state *SagaState) (interface{}, error) {
+					return nil, errors.New("out of stock")
+				},
+			},
+			{Name: "charge", Service: "payments", Action: "payments.charge"},
+		},
+	})
+
+	past := time.Now().Add(-time.Minute)
+	store.Save(&SagaState{DataTransactionID: "running", Saga: "checkout", Status: SagaRunning, UpdatedAt: past})
+	store.Save(&SagaState{DataTransactionID: "compensating", Saga: "checkout", Status: SagaCompensating, Step: 1, UpdatedAt: past})
+	store.Save(&SagaState{DataTransactionID: "recent", Saga: "checkout", Status: SagaRunning, UpdatedAt: time.Now()})
+
+	if err := gom.ResumeSagas(30 * time.Second); err != nil {
+		t.Fatalf("ResumeSagas failed: %s", err.Error())
+	}
+
+	for _, dtID := range []string{"running", "compensating"} {
+		state, err := gom.SagaState(dtID)
+		if err != nil {
+			t.Fatalf("SagaState failed: %s", err.Error())
+		}
+
+		if state.Status != SagaCompensated || state.Step != 0 {
+			t.Fatalf("expected %s to be compensated, got %s at step %d", dtID, state.Status, state.Step)
+		}
+	}
+
+	if state, _ := gom.SagaState("recent"); state.Status != SagaRunning {
+		t.Fatalf("recently updated sagas must not be resumed")
+	}
+
+	if _, err := gom.SagaState("unknown"); !errors.Is(err, ErrSagaNotFound) {
+		t.Fatalf("expected ErrSagaNotFound, got %v", err)
+	}
+}
+
+// claimingStore stands for another instance claiming every saga after it is listed
+type claimingStore struct {
+	*MemorySagaStore
+}
+
+func (store claimingStore) Unfinished() ([]*SagaState, error) {
+	states, err := store.MemorySagaStore.Unfinished()
+	for _, state := range states {
+		claimed := copySagaState(state)
+		store.Save(claimed)
+	}
+	return states, err
+}
+
+func TestResumeSagasClaim(t *testing.T) {
+	store := claimingStore{NewMemorySagaStore()}
+	gom := NewGommunicator(nil, nil, nil, "", "checkout", "", "").SetLogState(false).SetSagaStore(store)
+	gom.RegisterSaga(&Saga{Name: "checkout", Steps: []*SagaStep{{Name: "reserve", Service: "stock", Action: "stock.reserve"}}})
+
+	store.Save(&SagaState{DataTransactionID: "claimed", Saga: "checkout", Status: SagaCompensating, Step: 1, UpdatedAt: time.Now().Add(-time.Minute)})
+
+	if err := gom.ResumeSagas(30 * time.Second); err != nil {
+		t.Fatalf("expected claimed sagas to be skipped, got %v", err)
+	}
+
+	if state, _ := gom.SagaState("claimed"); state.Status != SagaCompensating || state.Version != 2 {
+		t.Fatalf("expected the saga to be left to its owner, got %s at version %d", state.Status, state.Version)
+	}
+
+	if _, err := gom.RunSaga("checkout", "claimed", nil); !errors.Is(err, ErrSagaConflict) {
+		t.Fatalf("expected a saga to run once per data transaction, got %v", err)
+	}
+}
+
+// sagaCluster returns a checkout service, and stock and payments services recording the actions they handle
+func sagaCluster() (checkout, stock, payments *Gommunicator, handled *recordingHooks) {
+	cluster := newFakeCluster()
+	handled = new(recordingHooks)
+	return cluster.service("checkout"), cluster.service("stock").AddHooks(handled), cluster.service("payments").AddHooks(handled), handled
+}
+
+func TestSagaResultCodec(t *testing.T) {
+	checkout, stock, _, _ := sagaCluster()
+
+	stock.SetActionCodec("stock.reserve", jsonTextCodec{})
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, map[string]string{"reservation": "r-1"})
+	})
+
+	checkout.RegisterSaga(&Saga{
+		Name:  "reserve",
+		Steps: []*SagaStep{{Name: "reserve", Service: "stock", Action: "stock.reserve", Timeout: 2}},
+	})
+
+	state, err := checkout.RunSaga("reserve", "dt-codec", map[string]int{"quantity": 1})
+	if err != nil || state.Status != SagaCompleted {
+		t.Fatalf("expected the saga to complete, got %v", err)
+	}
+
+	var result struct {
+		Reservation string `json:"reservation"`
+	}
+	if err := state.Result("reserve", &result); err != nil || result.Reservation != "r-1" {
+		t.Fatalf("expected the codec encoded result as JSON, got %s: %v", state.Results["reserve"], err)
+	}
+}
+
+func TestSagaCompensation(t *testing.T) {
+	checkout, stock, payments, handled := sagaCluster()
+
+	var refundFailures int32
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, map[string]string{"reservation": "r-1"})
+	})
+	stock.RegisterAction("stock.release", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, nil)
+	})
+	stock.RegisterAction("stock.ship", func(request *DataTransactionRequest) error {
+		return stock.RespondError(request, NewConflictError("shipment", "s-1", "no carrier", nil))
+	})
+	payments.RegisterAction("payments.charge", func(request *DataTransactionRequest) error {
+		return payments.Respond(request, map[string]string{"charge": "c-1"})
+	})
+	payments.RegisterAction("payments.refund", func(request *DataTransactionRequest) error {
+		if atomic.AddInt32(&refundFailures, -1) >= 0 {
+			return payments.RespondError(request, NewUnavailableError("bank", nil))
+		}
+
+		// The compensation gets the step result by default
+		var charge map[string]string
+		if err := request.Decode(&charge); err != nil || charge["charge"] != "c-1" {
+			return payments.RespondError(request, NewSimpleError(Basic, "refund without the charge"))
+		}
+		return payments.Respond(request, nil)
+	})
+
+	checkout.RegisterSaga(&Saga{
+		Name: "checkout",
+		Steps: []*SagaStep{
+			{Name: "reserve", Service: "stock", Action: "stock.reserve", CompensateAction: "stock.release", Timeout: 2},
+			{Name: "charge", Service: "payments", Action: "payments.charge", CompensateAction: "payments.refund", Timeout: 2},
+			{Name: "ship", Service: "stock", Action: "stock.ship", Timeout: 2},
+		},
+		CompensationRetries: 2,
+	})
+
+	// The refund fails once, then succeeds on its retry
+	atomic.StoreInt32(&refundFailures, 1)
+	state, err := checkout.RunSaga("checkout", "dt-compensated", nil)
+	if err != nil {
+		t.Fatalf("RunSaga failed: %s", err.Error())
+	}
+
+	if state.Status != SagaCompensated || state.Step != 0 {
+		t.Fatalf("expected the saga to be compensated, got %s at step %d: %s", state.Status, state.Step, state.Error)
+	}
+
+	expected := "stock.reserve,payments.charge,stock.ship,payments.refund,payments.refund,stock.release"
+	if actions := strings.Join(handled.actions(), ","); actions != expected {
+		t.Fatalf("expected the compensations in reverse order %s, got %s", expected, actions)
+	}
+
+	// A refund failing every retry leaves the saga failed, with the stock still reserved
+	atomic.StoreInt32(&refundFailures, 2)
+	state, err = checkout.RunSaga("checkout", "dt-failed", nil)
+	if err != nil {
+		t.Fatalf("RunSaga failed: %s", err.Error())
+	}
+
+	if state.Status != SagaFailed || state.Step != 2 {
+		t.Fatalf("expected the saga to fail on the refund, got %s at step %d", state.Status, state.Step)
+	}
+
+	if stored, _ := checkout.SagaState("dt-failed"); stored.Status != SagaFailed {
+		t.Fatalf("expected the failed saga to be persisted, got %s", stored.Status)
+	}
+}
+
+func TestSagaTimedOutStepCompensation(t *testing.T) {
+	checkout, stock, payments, handled := sagaCluster()
+
+	var charged int32
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, map[string]string{"reservation": "r-1"})
+	})
+	stock.RegisterAction("stock.release", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, nil)
+	})
+	payments.RegisterAction("payments.charge", func(request *DataTransactionRequest) error {
+		// The charge is applied but its response is lost
+		atomic.StoreInt32(&charged, 1)
+		return nil
+	})
+	payments.RegisterAction("payments.refund", func(request *DataTransactionRequest) error {
+		// Timed out steps are compensated with the saga input
+		var input map[string]int
+		if err := request.Decode(&input); err != nil || input["amount"] != 10 {
+			return payments.RespondError(request, NewSimpleError(Basic, "refund without the input"))
+		}
+
+		atomic.StoreInt32(&charged, 0)
+		return payments.Respond(request, nil)
+	})
+
+	checkout.RegisterSaga(&Saga{
+		Name: "checkout",
+		Steps: []*SagaStep{
+			{Name: "reserve", Service: "stock", Action: "stock.reserve", CompensateAction: "stock.release", Timeout: 2},
+			{Name: "charge", Service: "payments", Action: "payments.charge", CompensateAction: "payments.refund", Timeout: 1},
+		},
+	})
+
+	state, err := checkout.RunSaga("checkout", "dt-timeout", map[string]int{"amount": 10})
+	if err != nil {
+		t.Fatalf("RunSaga failed: %s", err.Error())
+	}
+
+	if state.Status != SagaCompensated || atomic.LoadInt32(&charged) != 0 {
+		t.Fatalf("expected the timed out charge to be refunded, got %s: %s", state.Status, state.Error)
+	}
+
+	expected := "stock.reserve,payments.charge,payments.refund,stock.release"
+	if actions := strings.Join(handled.actions(), ","); actions != expected {
+		t.Fatalf("expected %s, got %s", expected, actions)
+	}
+}
@@ -0,0 +1,242 @@
+package gommunicator
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// DefaultSignatureMaxAge is how old a signature may be before it is considered expired
+const DefaultSignatureMaxAge = 5 * time.Minute
+
+// Signature algorithms
+const (
+	HMACSHA256 = "hmac-sha256"
+	Ed25519    = "ed25519"
+)
+
+// ErrInvalidSignature is returned when an incoming message signature can't be verified
+var ErrInvalidSignature = errors.New("invalid message signature")
+
+// SigningKey signs the messages sent by this service
+type SigningKey interface {
+	Algorithm() string
+	Sign(payload []byte) ([]byte, error)
+}
+
+type hmacKey []byte
+
+func (key hmacKey) Algorithm() string {
+	return HMACSHA256
+}
+
+func (key hmacKey) Sign(payload []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+// NewHMACSigningKey returns a SigningKey signing with HMAC-SHA256
+func NewHMACSigningKey(secret []byte) SigningKey {
+	return hmacKey(secret)
+}
+
+type ed25519Key ed25519.PrivateKey
+
+func (key ed25519Key) Algorithm() string {
+	return Ed25519
+}
+
+func (key ed25519Key) Sign(payload []byte) ([]byte, error) {
+	return ed25519.Sign(ed25519.PrivateKey(key), payload), nil
+}
+
+// NewEd25519SigningKey returns a SigningKey signing with Ed25519
+func NewEd25519SigningKey(privateKey ed25519.PrivateKey) (SigningKey, error) {
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("ed25519 private key must be %d bytes, got %d", ed25519.PrivateKeySize, len(privateKey))
+	}
+
+	return ed25519Key(privateKey), nil
+}
+
+// KeyRing holds the verification keys of every service allowed to send messages
+type KeyRing struct {
+	lock    sync.RWMutex
+	hmac    map[string][]byte
+	ed25519 map[string]ed25519.PublicKey
+}
+
+// NewKeyRing returns a new empty KeyRing
+func NewKeyRing() *KeyRing {
+	return &KeyRing{
+		hmac:    make(map[string][]byte),
+		ed25519: make(map[string]ed25519.PublicKey),
+	}
+}
+
+// AddHMAC adds the HMAC secret of a service
+func (ring *KeyRing) AddHMAC(service string, secret []byte) *KeyRing {
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+	ring.hmac[service] = secret
+	return ring
+}
+
+// AddEd25519 adds the Ed25519 public key of a service
+// Messages of a service whose key is not ed25519.PublicKeySize bytes long are rejected
+func (ring *KeyRing) AddEd25519(service string, publicKey ed25519.PublicKey) *KeyRing {
+	ring.lock.Lock()
+	defer ring.lock.Unlock()
+	ring.ed25519[service] = publicKey
+	return ring
+}
+
+// Verify checks the signature of a payload sent by service
+func (ring *KeyRing) Verify(service, algorithm string, payload, signature []byte) error {
+	ring.lock.RLock()
+	defer ring.lock.RUnlock()
+
+	switch algorithm {
+	case HMACSHA256:
+		secret, ok := ring.hmac[service]
+		if !ok {
+			return fmt.Errorf("%w: no %s key for %s", ErrInvalidSignature, algorithm, service)
+		}
+
+		expected, _ := hmacKey(secret).Sign(payload)
+		if !hmac.Equal(expected, signature) {
+			return ErrInvalidSignature
+		}
+	case Ed25519:
+		publicKey, ok := ring.ed25519[service]
+		if !ok {
+			return fmt.Errorf("%w: no %s key for %s", ErrInvalidSignature, algorithm, service)
+		}
+
+		// ed25519.Verify panics on keys of another size
+		if len(publicKey) != ed25519.PublicKeySize {
+			return fmt.Errorf("%w: %s key of %s must be %d bytes, got %d", ErrInvalidSignature, algorithm, service, ed25519.PublicKeySize, len(publicKey))
+		}
+
+		if !ed25519.Verify(publicKey, payload, signature) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("%w: unknown algorithm %s", ErrInvalidSignature, algorithm)
+	}
+
+	return nil
+}
+
+// signingPayload is what gets signed: the time, every message attribute but the signature and the message
+// Attributes are canonicalized as a JSON object, whose keys are sorted
+func signingPayload(timestamp string, attributes map[string]string, message string) ([]byte, error) {
+	delete(attributes, "Signature")
+
+	canonical, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.Join([]string{timestamp, string(canonical), message}, "\n")), nil
+}
+
+// SetSigning signs outgoing messages with key and verifies incoming ones against the ring
+// Unsigned, tampered or older than maxAge messages are rejected, a zero maxAge means DefaultSignatureMaxAge
+func (gom *Gommunicator) SetSigning(key SigningKey, ring *KeyRing, maxAge time.Duration) *Gommunicator {
+	if maxAge <= 0 {
+		maxAge = DefaultSignatureMaxAge
+	}
+
+	gom.signingKey = key
+	gom.keyRing = ring
+	gom.signatureMaxAge = maxAge
+	return gom
+}
+
+// sign adds the Sender and Signature attributes to an outgoing message, signing all its attributes
+// It runs once every attribute is set, packing happens after it and is reverted before verify
+// The Signature attribute packs "algorithm;unix timestamp;base64 signature" to spare attributes
+func (gom *Gommunicator) sign(message string, attributes map[string]*sns.MessageAttributeValue) error {
+	if gom.signingKey == nil {
+		return nil
+	}
+
+	attributes["Sender"] = stringAttribute(gom.ServiceName)
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	payload, err := signingPayload(timestamp, attributeValues(attributes), message)
+	if err != nil {
+		return err
+	}
+
+	signature, err := gom.signingKey.Sign(payload)
+	if err != nil {
+		return err
+	}
+
+	attributes["Signature"] = stringAttribute(strings.Join([]string{
+		gom.signingKey.Algorithm(),
+		timestamp,
+		base64.StdEncoding.EncodeToString(signature),
+	}, ";"))
+
+	return nil
+}
+
+// verify checks the signature of an incoming message, returning the authenticated sender
+func (gom *Gommunicator) verify(message string, attributes map[string]interface{}) (string, error) {
+	if gom.keyRing == nil {
+		return "", nil
+	}
+
+	sender := messageAttribute(attributes, "Sender")
+	parts := strings.Split(messageAttribute(attributes, "Signature"), ";")
+	if sender == "" || len(parts) != 3 {
+		return "", fmt.Errorf("%w: message is not signed", ErrInvalidSignature)
+	}
+
+	algorithm, timestamp, encoded := parts[0], parts[1], parts[2]
+
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: bad timestamp", ErrInvalidSignature)
+	}
+
+	age := time.Since(time.Unix(signedAt, 0))
+	if age > gom.signatureMaxAge || age < -gom.signatureMaxAge {
+		return "", fmt.Errorf("%w: signature expired", ErrInvalidSignature)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("%w: bad encoding", ErrInvalidSignature)
+	}
+
+	values := make(map[string]string, len(attributes))
+	for name := range attributes {
+		values[name] = messageAttribute(attributes, name)
+	}
+
+	payload, err := signingPayload(timestamp, values, message)
+	if err != nil {
+		return "", err
+	}
+
+	if err := gom.keyRing.Verify(sender, algorithm, payload, signature); err != nil {
+		return "", err
+	}
+
+	return sender, nil
+}
@@ -6,6 +6,8 @@ type SimpleErrorCode string
 const (
 	// Basic error
 	Basic SimpleErrorCode = "BASIC"
+	// Forbidden error, the calling service is not allowed to call the action
+	Forbidden SimpleErrorCode = "FORBIDDEN"
 )
 
 // SimpleError structure
@@ -25,17 +27,9 @@ func NewSimpleError(code SimpleErrorCode, message string) *SimpleError {
 }
 
 // GetMessage the simple error message
+// Codes are free form, localized messages per code are provided by an ErrorCatalog
 func (err *SimpleError) GetMessage() string {
-	code := SimpleErrorCode(err.GetCode())
-	switch code {
-	case Basic:
-		{
-			return err.Message
-		}
-	default:
-		break
-	}
-	return ""
+	return err.Message
 }
 
 // GetCode returns the simple error code
@@ -56,3 +50,9 @@ func (err *SimpleError) GetContext() map[string]interface{} {
 func (err *SimpleError) Error() string {
 	return err.GetMessage()
 }
+
+// Is reports if target is a MapErr with the same code, errors.Is(err, NewSimpleError(Forbidden, ""))
+func (err *SimpleError) Is(target error) bool {
+	mapErr, ok := target.(MapErr)
+	return ok && mapErr.GetCode() == err.GetCode()
+}
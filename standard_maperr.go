@@ -0,0 +1,312 @@
+package gommunicator
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Standard error codes
+const (
+	NotFound     SimpleErrorCode = "NOT_FOUND"
+	Conflict     SimpleErrorCode = "CONFLICT"
+	Unauthorized SimpleErrorCode = "UNAUTHORIZED"
+	RateLimited  SimpleErrorCode = "RATE_LIMITED"
+	Timeout      SimpleErrorCode = "TIMEOUT"
+	Unavailable  SimpleErrorCode = "UNAVAILABLE"
+)
+
+// Retryable is implemented by errors telling whether the failed call may succeed if retried
+type Retryable interface {
+	Retryable() bool
+}
+
+// IsRetryable reports if an error, or any error it wraps, is retryable
+func IsRetryable(err error) bool {
+	var retryable Retryable
+	return errors.As(err, &retryable) && retryable.Retryable()
+}
+
+// baseError holds what every standard MapErr has: a message and the Go error it wraps
+type baseError struct {
+	Message string
+	Cause   error
+}
+
+// GetMessage returns the error message
+func (err *baseError) GetMessage() string {
+	return err.Message
+}
+
+// Unwrap returns the original Go error
+func (err *baseError) Unwrap() error {
+	return err.Cause
+}
+
+func (err *baseError) Error() string {
+	return err.Message
+}
+
+func sameCode(code SimpleErrorCode, target error) bool {
+	mapErr, ok := target.(MapErr)
+	return ok && mapErr.GetCode() == string(code)
+}
+
+// NotFoundError is returned when a resource does not exist
+type NotFoundError struct {
+	baseError
+	Resource string
+	ID       string
+}
+
+// NewNotFoundError returns a new NotFoundError
+func NewNotFoundError(resource, id string, cause error) *NotFoundError {
+	return &NotFoundError{
+		baseError: baseError{Message: fmt.Sprintf("%s %s not found", resource, id), Cause: cause},
+		Resource:  resource,
+		ID:        id,
+	}
+}
+
+// GetCode returns NotFound
+func (err *NotFoundError) GetCode() string { return string(NotFound) }
+
+// GetType returns NotFoundErrorType
+func (err *NotFoundError) GetType() ErrType { return NotFoundErrorType }
+
+// GetContext returns the resource and id not found
+func (err *NotFoundError) GetContext() map[string]interface{} {
+	return map[string]interface{}{"resource": err.Resource, "id": err.ID}
+}
+
+// Retryable returns false, the resource won't appear by retrying
+func (err *NotFoundError) Retryable() bool { return false }
+
+// Is reports if target is a MapErr with the same code
+func (err *NotFoundError) Is(target error) bool { return sameCode(NotFound, target) }
+
+// ConflictError is returned when a resource state prevents the action
+type ConflictError struct {
+	baseError
+	Resource string
+	ID       string
+}
+
+// NewConflictError returns a new ConflictError
+func NewConflictError(resource, id, message string, cause error) *ConflictError {
+	return &ConflictError{
+		baseError: baseError{Message: message, Cause: cause},
+		Resource:  resource,
+		ID:        id,
+	}
+}
+
+// GetCode returns Conflict
+func (err *ConflictError) GetCode() string { return string(Conflict) }
+
+// GetType returns ConflictErrorType
+func (err *ConflictError) GetType() ErrType { return ConflictErrorType }
+
+// GetContext returns the conflicting resource and id
+func (err *ConflictError) GetContext() map[string]interface{} {
+	return map[string]interface{}{"resource": err.Resource, "id": err.ID}
+}
+
+// Retryable returns false, the conflict must be solved first
+func (err *ConflictError) Retryable() bool { return false }
+
+// Is reports if target is a MapErr with the same code
+func (err *ConflictError) Is(target error) bool { return sameCode(Conflict, target) }
+
+// UnauthorizedError is returned when the caller lacks the credentials or permissions for the action
+type UnauthorizedError struct {
+	baseError
+	Reason string
+}
+
+// NewUnauthorizedError returns a new UnauthorizedError
+func NewUnauthorizedError(reason string, cause error) *UnauthorizedError {
+	return &UnauthorizedError{
+		baseError: baseError{Message: fmt.Sprintf("unauthorized: %s", reason), Cause: cause},
+		Reason:    reason,
+	}
+}
+
+// GetCode returns Unauthorized
+func (err *UnauthorizedError) GetCode() string { return string(Unauthorized) }
+
+// GetType returns UnauthorizedErrorType
+func (err *UnauthorizedError) GetType() ErrType { return UnauthorizedErrorType }
+
+// GetContext returns the reason
+func (err *UnauthorizedError) GetContext() map[string]interface{} {
+	return map[string]interface{}{"reason": err.Reason}
+}
+
+// Retryable returns false
+func (err *UnauthorizedError) Retryable() bool { return false }
+
+// Is reports if target is a MapErr with the same code
+func (err *UnauthorizedError) Is(target error) bool { return sameCode(Unauthorized, target) }
+
+// RateLimitedError is returned when the caller exceeded its quota
+type RateLimitedError struct {
+	baseError
+	RetryAfter time.Duration
+}
+
+// NewRateLimitedError returns a new RateLimitedError
+func NewRateLimitedError(retryAfter time.Duration, cause error) *RateLimitedError {
+	return &RateLimitedError{
+		baseError:  baseError{Message: fmt.Sprintf("rate limited, retry after %s", retryAfter), Cause: cause},
+		RetryAfter: retryAfter,
+	}
+}
+
+// GetCode returns RateLimited
+func (err *RateLimitedError) GetCode() string { return string(RateLimited) }
+
+// GetType returns RateLimitedErrorType
+func (err *RateLimitedError) GetType() ErrType { return RateLimitedErrorType }
+
+// GetContext returns when to retry in milliseconds
+func (err *RateLimitedError) GetContext() map[string]interface{} {
+	return map[string]interface{}{"retryAfterMs": err.RetryAfter.Milliseconds()}
+}
+
+// Retryable returns true, after waiting RetryAfter
+func (err *RateLimitedError) Retryable() bool { return true }
+
+// Is reports if target is a MapErr with the same code
+func (err *RateLimitedError) Is(target error) bool { return sameCode(RateLimited, target) }
+
+// TimeoutError is returned when a call to another service timed out
+type TimeoutError struct {
+	baseError
+	Service string
+	Action  string
+	Timeout time.Duration
+}
+
+// NewTimeoutError returns a new TimeoutError
+func NewTimeoutError(service, action string, timeout time.Duration, cause error) *TimeoutError {
+	return &TimeoutError{
+		baseError: baseError{Message: fmt.Sprintf("%s.%s timed out after %s", service, action, timeout), Cause: cause},
+		Service:   service,
+		Action:    action,
+		Timeout:   timeout,
+	}
+}
+
+// GetCode returns Timeout
+func (err *TimeoutError) GetCode() string { return string(Timeout) }
+
+// GetType returns TimeoutErrorType
+func (err *TimeoutError) GetType() ErrType { return TimeoutErrorType }
+
+// GetContext returns the service and action timing out and the timeout in milliseconds
+func (err *TimeoutError) GetContext() map[string]interface{} {
+	return map[string]interface{}{
+		"service":   err.Service,
+		"action":    err.Action,
+		"timeoutMs": err.Timeout.Milliseconds(),
+	}
+}
+
+// Retryable returns true
+func (err *TimeoutError) Retryable() bool { return true }
+
+// Is reports if target is a MapErr with the same code
+func (err *TimeoutError) Is(target error) bool { return sameCode(Timeout, target) }
+
+// UnavailableError is returned when a dependency (database, service, API) is unavailable
+type UnavailableError struct {
+	baseError
+	Dependency string
+}
+
+// NewUnavailableError returns a new UnavailableError
+func NewUnavailableError(dependency string, cause error) *UnavailableError {
+	return &UnavailableError{
+		baseError:  baseError{Message: fmt.Sprintf("%s is unavailable", dependency), Cause: cause},
+		Dependency: dependency,
+	}
+}
+
+// GetCode returns Unavailable
+func (err *UnavailableError) GetCode() string { return string(Unavailable) }
+
+// GetType returns UnavailableErrorType
+func (err *UnavailableError) GetType() ErrType { return UnavailableErrorType }
+
+// GetContext returns the unavailable dependency
+func (err *UnavailableError) GetContext() map[string]interface{} {
+	return map[string]interface{}{"dependency": err.Dependency}
+}
+
+// Retryable returns true
+func (err *UnavailableError) Retryable() bool { return true }
+
+// Is reports if target is a MapErr with the same code
+func (err *UnavailableError) Is(target error) bool { return sameCode(Unavailable, target) }
+
+// contextString reads a string from a remote error context
+func contextString(remote *RemoteError, key string) string {
+	value, _ := remote.Context[key].(string)
+	return value
+}
+
+// contextDuration reads a milliseconds duration from a remote error context
+func contextDuration(remote *RemoteError, key string) time.Duration {
+	var ms int64
+	remote.Decode(key, &ms)
+	return time.Duration(ms) * time.Millisecond
+}
+
+func init() {
+	RegisterRemoteError(string(NotFound), func(remote *RemoteError) error {
+		return &NotFoundError{
+			baseError: baseError{Message: remote.Message},
+			Resource:  contextString(remote, "resource"),
+			ID:        contextString(remote, "id"),
+		}
+	})
+
+	RegisterRemoteError(string(Conflict), func(remote *RemoteError) error {
+		return &ConflictError{
+			baseError: baseError{Message: remote.Message},
+			Resource:  contextString(remote, "resource"),
+			ID:        contextString(remote, "id"),
+		}
+	})
+
+	RegisterRemoteError(string(Unauthorized), func(remote *RemoteError) error {
+		return &UnauthorizedError{
+			baseError: baseError{Message: remote.Message},
+			Reason:    contextString(remote, "reason"),
+		}
+	})
+
+	RegisterRemoteError(string(RateLimited), func(remote *RemoteError) error {
+		return &RateLimitedError{
+			baseError:  baseError{Message: remote.Message},
+			RetryAfter: contextDuration(remote, "retryAfterMs"),
+		}
+	})
+
+	RegisterRemoteError(string(Timeout), func(remote *RemoteError) error {
+		return &TimeoutError{
+			baseError: baseError{Message: remote.Message},
+			Service:   contextString(remote, "service"),
+			Action:    contextString(remote, "action"),
+			Timeout:   contextDuration(remote, "timeoutMs"),
+		}
+	})
+
+	RegisterRemoteError(string(Unavailable), func(remote *RemoteError) error {
+		return &UnavailableError{
+			baseError:  baseError{Message: remote.Message},
+			Dependency: contextString(remote, "dependency"),
+		}
+	})
+}
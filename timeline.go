@@ -0,0 +1,254 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// Timeline event types
+const (
+	EventRequestSent      = "request_sent"      // Exec published a request
+	EventRequestHandled   = "request_handled"   // A service handled a request
+	EventDuplicateIgnored = "duplicate_ignored" // A service ignored a message already handled
+	EventResponseSent     = "response_sent"     // A service answered a request
+	EventResponseReceived = "response_received" // Exec got its response
+	EventTimeout          = "timeout"           // Exec got no response in time
+)
+
+// Timeline event outcomes
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+	OutcomeTimeout = "timeout"
+)
+
+// ErrTransactionNotFound is returned when a data transaction has no timeline
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// TimelineEvent is something that happened to a data transaction on a service
+type TimelineEvent struct {
+	Time      time.Time     `json:"time"`
+	Type      string        `json:"type"`
+	Service   string        `json:"service"`        // Service recording the event
+	Peer      string        `json:"peer,omitempty"` // Other service of the request or response
+	Action    string        `json:"action"`
+	ActionID  string        `json:"actionId,omitempty"`
+	Outcome   string        `json:"outcome,omitempty"`
+	ErrorCode string        `json:"errorCode,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration,omitempty"` // Handling time, or round trip for Exec
+}
+
+// Transaction is the timeline of a data transaction across services
+type Transaction struct {
+	ID     string           `json:"id"`
+	Events []*TimelineEvent `json:"events"`
+}
+
+// TimelineStore persists the timeline events of data transactions
+// Services of a cluster share a store to see transactions end to end
+type TimelineStore interface {
+	Append(dtID string, event *TimelineEvent) error
+	// Events returns the events of a data transaction, none when it is unknown
+	Events(dtID string) ([]*TimelineEvent, error)
+}
+
+// SetTimelineStore sets the store recording the timeline of the data transactions, none by default
+func (gom *Gommunicator) SetTimelineStore(store TimelineStore) *Gommunicator {
+	gom.timeline = store
+	return gom
+}
+
+// GetTransaction returns the timeline of a data transaction, sorted by time
+func (gom *Gommunicator) GetTransaction(id string) (*Transaction, error) {
+	if gom.timeline == nil {
+		return nil, errors.New("no timeline store set")
+	}
+
+	events, err := gom.timeline.Events(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		return nil, ErrTransactionNotFound
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Time.Before(events[j].Time)
+	})
+
+	return &Transaction{ID: id, Events: events}, nil
+}
+
+// recordEvent appends an event to the timeline of a data transaction
+// Failures are logged only, the timeline must not break the transaction
+func (gom *Gommunicator) recordEvent(dtID string, event *TimelineEvent) {
+	if gom.timeline == nil || dtID == "" {
+		return
+	}
+
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	event.Service = gom.ServiceName
+
+	if err := gom.timeline.Append(dtID, event); err != nil {
+		gom.tryLogErr("Timeline event could not be recorded", F(FieldDataTransactionID, dtID), F("event", event.Type), errorField(err))
+	}
+}
+
+// errorOutcome fills the outcome of an event from an error
+func (event *TimelineEvent) errorOutcome(err error) *TimelineEvent {
+	if err == nil {
+		event.Outcome = OutcomeSuccess
+		return event
+	}
+
+	event.Outcome = OutcomeError
+	event.Error = err.Error()
+
+	var mapErr MapErr
+	if errors.As(err, &mapErr) {
+		event.ErrorCode = mapErr.GetCode()
+		event.Error = firstLine(mapErr.GetMessage())
+	}
+
+	return event
+}
+
+// responseOutcome fills the outcome of an event from a response
+func (event *TimelineEvent) responseOutcome(response *DataTransactionResponse) *TimelineEvent {
+	return event.errorOutcome(response.Err())
+}
+
+// MemoryTimelineStore is an in process TimelineStore, useful for tests and single binary clusters
+type MemoryTimelineStore struct {
+	lock   sync.RWMutex
+	events map[string][]*TimelineEvent
+}
+
+// NewMemoryTimelineStore returns a new MemoryTimelineStore
+func NewMemoryTimelineStore() *MemoryTimelineStore {
+	return &MemoryTimelineStore{events: make(map[string][]*TimelineEvent)}
+}
+
+// Append appends a copy of the event
+func (store *MemoryTimelineStore) Append(dtID string, event *TimelineEvent) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	copied := *event
+	store.events[dtID] = append(store.events[dtID], &copied)
+	return nil
+}
+
+// Events returns copies of the events of a data transaction
+func (store *MemoryTimelineStore) Events(dtID string) ([]*TimelineEvent, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	events := make([]*TimelineEvent, len(store.events[dtID]))
+	for i, event := range store.events[dtID] {
+		copied := *event
+		events[i] = &copied
+	}
+
+	return events, nil
+}
+
+const timelineKeyPrefix = "timeline#"
+
+// DynamoTimelineStore is a TimelineStore backed by a DynamoDB table with a single "id" hash key
+// Every data transaction is an item listing its events, bounded by the 400KB DynamoDB item size
+// Give timelines a table of their own, with its own TTL, rather than the dedup table read on every message:
+//
+//	gom.SetTimelineStore(NewDynamoTimelineStore(dynamo, "timelines", 30*24*time.Hour))
+type DynamoTimelineStore struct {
+	dynamo dynamodbiface.DynamoDBAPI
+	table  string
+	ttl    time.Duration
+}
+
+// NewDynamoTimelineStore returns a new DynamoTimelineStore
+// When ttl is set, items carry an "expiresAt" attribute usable as the table TTL attribute
+func NewDynamoTimelineStore(dynamo dynamodbiface.DynamoDBAPI, table string, ttl time.Duration) *DynamoTimelineStore {
+	return &DynamoTimelineStore{
+		dynamo: dynamo,
+		table:  table,
+		ttl:    ttl,
+	}
+}
+
+// Append appends the event to the item of the data transaction
+func (store *DynamoTimelineStore) Append(dtID string, event *TimelineEvent) error {
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	update := "SET events = list_append(if_not_exists(events, :empty), :event)"
+	values := map[string]*dynamodb.AttributeValue{
+		":empty": {L: []*dynamodb.AttributeValue{}},
+		":event": {L: []*dynamodb.AttributeValue{{S: aws.String(string(raw))}}},
+	}
+
+	if store.ttl > 0 {
+		update += ", expiresAt = :expiresAt"
+		values[":expiresAt"] = &dynamodb.AttributeValue{
+			N: aws.String(strconv.FormatInt(event.Time.Add(store.ttl).Unix(), 10)),
+		}
+	}
+
+	_, err = store.dynamo.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(store.table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(timelineKeyPrefix + dtID),
+			},
+		},
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeValues: values,
+	})
+
+	return err
+}
+
+// Events returns the events of a data transaction
+func (store *DynamoTimelineStore) Events(dtID string) ([]*TimelineEvent, error) {
+	output, err := store.dynamo.GetItem(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(timelineKeyPrefix + dtID),
+			},
+		},
+		TableName: aws.String(store.table),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*TimelineEvent, 0)
+	if output.Item == nil || output.Item["events"] == nil {
+		return events, nil
+	}
+
+	for _, value := range output.Item["events"].L {
+		event := new(TimelineEvent)
+		if err := json.Unmarshal([]byte(aws.StringValue(value.S)), event); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,80 @@
+package gommunicator
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetTransaction(t *testing.T) {
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false)
+
+	if _, err := gom.GetTransaction("dt"); err == nil {
+		t.Fatalf("expected an error without timeline store")
+	}
+
+	gom.SetTimelineStore(NewMemoryTimelineStore())
+
+	now := time.Now()
+	gom.recordEvent("dt", (&TimelineEvent{Time: now.Add(time.Second), Type: EventResponseReceived, Action: "stock.reserve"}).
+		errorOutcome(NewNotFoundError("product", "42", nil)))
+	gom.recordEvent("dt", &TimelineEvent{Time: now, Type: EventRequestSent, Action: "stock.reserve"})
+
+	transaction, err := gom.GetTransaction("dt")
+	if err != nil {
+		t.Fatalf("GetTransaction failed: %s", err.Error())
+	}
+
+	if len(transaction.Events) != 2 || transaction.Events[0].Type != EventRequestSent || transaction.Events[0].Service != "orders" {
+		t.Fatalf("unexpected timeline: %+v", transaction.Events)
+	}
+
+	received := transaction.Events[1]
+	if received.Outcome != OutcomeError || received.ErrorCode != string(NotFound) {
+		t.Fatalf("unexpected outcome: %+v", received)
+	}
+
+	if _, err := gom.GetTransaction("unknown"); !errors.Is(err, ErrTransactionNotFound) {
+		t.Fatalf("expected ErrTransactionNotFound, got %v", err)
+	}
+}
+
+// blockingTimelineStore holds the appends of received responses until released
+type blockingTimelineStore struct {
+	*MemoryTimelineStore
+	release chan struct{}
+}
+
+func (store blockingTimelineStore) Append(dtID string, event *TimelineEvent) error {
+	if event.Type == EventResponseReceived {
+		<-store.release
+	}
+	return store.MemoryTimelineStore.Append(dtID, event)
+}
+
+func TestTimelineAfterDelivery(t *testing.T) {
+	cluster := newFakeCluster()
+	store := blockingTimelineStore{NewMemoryTimelineStore(), make(chan struct{})}
+	orders := cluster.service("orders").SetTimelineStore(store)
+	stock := cluster.service("stock")
+
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, nil)
+	})
+
+	receiver, err := orders.Exec(&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve", Timeout: 2})
+	if err != nil {
+		t.Fatalf("Exec failed: %s", err.Error())
+	}
+
+	select {
+	case response := <-receiver:
+		if response == nil {
+			t.Fatalf("expected the response, got a timeout")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the response to be delivered before the timeline is written")
+	}
+
+	close(store.release)
+}
@@ -0,0 +1,137 @@
+package gommunicator
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/service/sns"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/kelvne/gommunicator"
+
+// Span attribute keys
+const (
+	SpanService           = attribute.Key("gommunicator.service")
+	SpanAction            = attribute.Key("gommunicator.action")
+	SpanIncomingService   = attribute.Key("gommunicator.incoming_service")
+	SpanDataTransactionID = attribute.Key("gommunicator.data_transaction_id")
+	SpanActionID          = attribute.Key("gommunicator.action_id")
+	SpanDuplicate         = attribute.Key("gommunicator.duplicate")
+	SpanSuccess           = attribute.Key("gommunicator.response.success")
+	SpanTimeout           = attribute.Key("gommunicator.timeout")
+)
+
+// W3C trace context travels in the traceparent and tracestate message attributes
+var traceContext = propagation.TraceContext{}
+
+// SetTracerProvider sets the OpenTelemetry tracer provider, the global provider is used by default
+func (gom *Gommunicator) SetTracerProvider(provider trace.TracerProvider) *Gommunicator {
+	gom.tracerProvider = provider
+	return gom
+}
+
+func (gom *Gommunicator) tracer() trace.Tracer {
+	if gom.tracerProvider == nil {
+		return otel.GetTracerProvider().Tracer(tracerName)
+	}
+
+	return gom.tracerProvider.Tracer(tracerName)
+}
+
+// attributeCarrier writes the trace context into SNS message attributes
+type attributeCarrier map[string]*sns.MessageAttributeValue
+
+func (carrier attributeCarrier) Get(key string) string {
+	if attribute, ok := carrier[key]; ok && attribute.StringValue != nil {
+		return *attribute.StringValue
+	}
+
+	return ""
+}
+
+func (carrier attributeCarrier) Set(key, value string) {
+	carrier[key] = stringAttribute(value)
+}
+
+func (carrier attributeCarrier) Keys() []string {
+	keys := make([]string, 0, len(carrier))
+	for key := range carrier {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// notificationCarrier reads the trace context from the message attributes of a SNS notification
+type notificationCarrier map[string]interface{}
+
+func (carrier notificationCarrier) Get(key string) string {
+	return messageAttribute(carrier, key)
+}
+
+func (carrier notificationCarrier) Set(key, value string) {}
+
+func (carrier notificationCarrier) Keys() []string {
+	keys := make([]string, 0, len(carrier))
+	for key := range carrier {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+func injectTraceContext(ctx context.Context, attributes map[string]*sns.MessageAttributeValue) {
+	traceContext.Inject(ctx, attributeCarrier(attributes))
+}
+
+func extractTraceContext(attributes map[string]interface{}) context.Context {
+	return traceContext.Extract(context.Background(), notificationCarrier(attributes))
+}
+
+func requestSpanAttributes(request *DataTransactionRequest) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		SpanService.String(request.Service),
+		SpanAction.String(request.Action),
+		SpanIncomingService.String(request.IncomingService),
+		SpanDataTransactionID.String(request.ID),
+		SpanActionID.String(actionIDValue(request.ActionID)),
+	}
+}
+
+func responseSpanAttributes(response *DataTransactionResponse) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		SpanAction.String(response.Action),
+		SpanDataTransactionID.String(response.ID),
+		SpanActionID.String(actionIDValue(response.ActionID)),
+		SpanSuccess.Bool(response.Success),
+	}
+}
+
+// endSpan records err, if any, and ends the span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// Context returns the context of the request, carrying the span of the handling
+func (dt *DataTransactionRequest) Context() context.Context {
+	if dt.ctx == nil {
+		return context.Background()
+	}
+
+	return dt.ctx
+}
+
+// WithContext sets the context of the request
+func (dt *DataTransactionRequest) WithContext(ctx context.Context) *DataTransactionRequest {
+	dt.ctx = ctx
+	return dt
+}
@@ -0,0 +1,43 @@
+package gommunicator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sns"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceContextPropagation(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false).SetTracerProvider(provider)
+
+	ctx, client := gom.tracer().Start(context.Background(), "exec", trace.WithSpanKind(trace.SpanKindClient))
+
+	attributes := map[string]*sns.MessageAttributeValue{
+		"Service": stringAttribute("payments"),
+		"Action":  stringAttribute("charge"),
+	}
+	injectTraceContext(ctx, attributes)
+
+	if _, ok := attributes["traceparent"]; !ok {
+		t.Fatalf("expected the traceparent attribute to be set")
+	}
+
+	_, server := gom.tracer().Start(extractTraceContext(notificationAttributes(attributes)), "handle")
+	server.End()
+	client.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	handled, executed := spans[0], spans[1]
+	if handled.SpanContext.TraceID() != executed.SpanContext.TraceID() || handled.Parent.SpanID() != executed.SpanContext.SpanID() {
+		t.Fatalf("expected the handling span to be a child of the exec span")
+	}
+}
@@ -0,0 +1,325 @@
+package gommunicator
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+)
+
+// DefaultCompressionThreshold is the body size, in bytes, above which messages get compressed
+const DefaultCompressionThreshold = 64 * 1024
+
+// MaxDecompressedSize is the size, in bytes, above which incoming bodies are not decompressed
+// It stops small compressed messages from expanding into huge ones, bigger payloads go through the claim check
+const MaxDecompressedSize = 16 * 1024 * 1024
+
+// ErrDecompressedTooLarge is returned by compressors when a body decompresses to more than MaxDecompressedSize
+var ErrDecompressedTooLarge = errors.New("decompressed message too large")
+
+// Compressor compresses message bodies
+// Decompress must fail with ErrDecompressedTooLarge instead of expanding a body over MaxDecompressedSize
+type Compressor interface {
+	Encoding() string
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Encoding() string {
+	return "gzip"
+}
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err = ioutil.ReadAll(io.LimitReader(reader, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > MaxDecompressedSize {
+		return nil, ErrDecompressedTooLarge
+	}
+
+	return data, nil
+}
+
+// GzipCompressor compresses bodies with gzip
+var GzipCompressor Compressor = gzipCompressor{}
+
+var compressors = map[string]Compressor{"gzip": GzipCompressor}
+var compressorsLock sync.RWMutex
+
+// RegisterCompressor makes a compressor available for decompressing incoming messages
+func RegisterCompressor(compressor Compressor) {
+	compressorsLock.Lock()
+	defer compressorsLock.Unlock()
+	compressors[compressor.Encoding()] = compressor
+}
+
+func compressorFor(encoding string) (Compressor, error) {
+	compressorsLock.RLock()
+	defer compressorsLock.RUnlock()
+
+	if compressor, ok := compressors[encoding]; ok == true {
+		return compressor, nil
+	}
+
+	return nil, fmt.Errorf("no compressor registered for content encoding %s", encoding)
+}
+
+// SetCompression enables compression of outgoing messages bigger than threshold bytes
+// A threshold lower or equal to zero means DefaultCompressionThreshold
+func (gom *Gommunicator) SetCompression(compressor Compressor, threshold int) *Gommunicator {
+	if threshold <= 0 {
+		threshold = DefaultCompressionThreshold
+	}
+
+	RegisterCompressor(compressor)
+	gom.compressor = compressor
+	gom.compressionThreshold = threshold
+	return gom
+}
+
+func stringAttribute(value string) *sns.MessageAttributeValue {
+	return &sns.MessageAttributeValue{
+		StringValue: aws.String(value),
+		DataType:    aws.String("String"),
+	}
+}
+
+func attributeValue(attributes map[string]*sns.MessageAttributeValue, name string) string {
+	if attribute, ok := attributes[name]; ok && attribute.StringValue != nil {
+		return *attribute.StringValue
+	}
+
+	return ""
+}
+
+// attributeValues returns the string values of message attributes
+func attributeValues(attributes map[string]*sns.MessageAttributeValue) map[string]string {
+	values := make(map[string]string, len(attributes))
+	for name, attribute := range attributes {
+		values[name] = aws.StringValue(attribute.StringValue)
+	}
+	return values
+}
+
+// SNS accepts up to 10 message attributes per message
+const maxMessageAttributes = 10
+
+// packedAttribute carries the transport attributes of a message packed as a JSON object
+const packedAttribute = "Envelope"
+
+// transportAttributes are only read by the receiving Gommunicator, unlike Service, Action and the
+// attribute headers used by filter policies, so they can be packed
+var transportAttributes = []string{
+	"ContentType", "ContentEncoding", "EncryptionKeyID", "EncryptedDataKey", "Sender", "Signature", "traceparent", "tracestate",
+}
+
+// ErrTooManyAttributes is returned when a message needs more attributes than SNS accepts, even packed
+var ErrTooManyAttributes = errors.New("too many message attributes")
+
+// packAttributes folds the transport attributes into the Envelope attribute when a message exceeds the SNS limit
+func packAttributes(attributes map[string]*sns.MessageAttributeValue) error {
+	if len(attributes) <= maxMessageAttributes {
+		return nil
+	}
+
+	packed := make(map[string]string)
+	for _, name := range transportAttributes {
+		if attribute, ok := attributes[name]; ok {
+			packed[name] = aws.StringValue(attribute.StringValue)
+			delete(attributes, name)
+		}
+	}
+
+	raw, err := json.Marshal(packed)
+	if err != nil {
+		return err
+	}
+	attributes[packedAttribute] = stringAttribute(string(raw))
+
+	if len(attributes) > maxMessageAttributes {
+		return fmt.Errorf("%w: %d attributes once packed, SNS accepts %d, map fewer attribute headers", ErrTooManyAttributes, len(attributes), maxMessageAttributes)
+	}
+
+	return nil
+}
+
+// unpackAttributes reverts packAttributes on the attributes of a SNS notification
+func unpackAttributes(attributes map[string]interface{}) error {
+	raw := messageAttribute(attributes, packedAttribute)
+	if raw == "" {
+		return nil
+	}
+
+	var packed map[string]string
+	if err := json.Unmarshal([]byte(raw), &packed); err != nil {
+		return fmt.Errorf("invalid %s attribute: %w", packedAttribute, err)
+	}
+
+	delete(attributes, packedAttribute)
+	for name, value := range packed {
+		attributes[name] = map[string]interface{}{"Type": "String", "Value": value}
+	}
+
+	return nil
+}
+
+// encodeBody turns a serialized envelope into the SNS message
+// The body is compressed, then encrypted, when configured; binary results are base64 encoded
+func (gom *Gommunicator) encodeBody(body []byte, attributes map[string]*sns.MessageAttributeValue) (string, error) {
+	binary := false
+	raw := len(body)
+
+	if gom.compressor != nil && len(body) > gom.compressionThreshold {
+		compressed, err := gom.compressor.Compress(body)
+		if err != nil {
+			return "", err
+		}
+
+		if base64.StdEncoding.EncodedLen(len(compressed)) < len(body) {
+			gom.tryLogInfo("Message compressed",
+				F(FieldService, attributeValue(attributes, "Service")),
+				F(FieldAction, attributeValue(attributes, "Action")),
+				F("encoding", gom.compressor.Encoding()),
+				F("bytes", len(body)),
+				F("compressed_bytes", len(compressed)),
+			)
+
+			attributes["ContentEncoding"] = stringAttribute(gom.compressor.Encoding())
+			body = compressed
+			binary = true
+		}
+	}
+
+	gom.metrics.MessageSize(raw, len(body))
+
+	if gom.encrypter != nil {
+		encrypted, err := gom.encrypter.encrypt(body, attributes)
+		if err != nil {
+			return "", err
+		}
+
+		body = encrypted
+		binary = true
+	}
+
+	if binary {
+		return base64.StdEncoding.EncodeToString(body), nil
+	}
+
+	return string(body), nil
+}
+
+// decodeBody reverts encodeBody using the SNS notification attributes
+func (gom *Gommunicator) decodeBody(message string, attributes map[string]interface{}) ([]byte, error) {
+	encoding := messageAttribute(attributes, "ContentEncoding")
+	keyID := messageAttribute(attributes, "EncryptionKeyID")
+
+	if encoding == "" && keyID == "" {
+		return []byte(message), nil
+	}
+
+	body, err := base64.StdEncoding.DecodeString(message)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyID != "" {
+		if gom.encrypter == nil {
+			return nil, errors.New("received an encrypted message but no key provider is configured")
+		}
+
+		body, err = gom.encrypter.decrypt(body, keyID, messageAttribute(attributes, "EncryptedDataKey"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if encoding != "" {
+		compressor, err := compressorFor(encoding)
+		if err != nil {
+			return nil, err
+		}
+
+		compressedSize := len(body)
+		body, err = compressor.Decompress(body)
+		if err != nil {
+			return nil, err
+		}
+
+		gom.tryLogInfo("Message decompressed",
+			F(FieldService, messageAttribute(attributes, "Service")),
+			F(FieldAction, messageAttribute(attributes, "Action")),
+			F("encoding", encoding),
+			F("bytes", len(body)),
+			F("compressed_bytes", compressedSize),
+		)
+	}
+
+	return body, nil
+}
+
+// publish publishes a serialized envelope to the orchestrator topic
+func (gom *Gommunicator) publish(body []byte, attributes map[string]*sns.MessageAttributeValue) error {
+	message, err := gom.encodeBody(body, attributes)
+	if err != nil {
+		return err
+	}
+
+	if err := gom.sign(message, attributes); err != nil {
+		return err
+	}
+
+	if err := packAttributes(attributes); err != nil {
+		return err
+	}
+
+	_, err = gom.orchestrator.Publish(
+		&sns.PublishInput{
+			TopicArn:          aws.String(gom.SNSTopicARN),
+			Message:           aws.String(message),
+			MessageAttributes: attributes,
+		},
+	)
+
+	gom.hooks.OnPublish(&PublishedMessage{
+		Body:       body,
+		Message:    message,
+		Attributes: attributeValues(attributes),
+		Err:        err,
+	})
+
+	return err
+}
@@ -0,0 +1,344 @@
+package gommunicator
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/service/sns"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// notificationAttributes mimics the attributes of a SNS notification delivered to SQS
+func notificationAttributes(attributes map[string]*sns.MessageAttributeValue) map[string]interface{} {
+	notification := make(map[string]interface{})
+	for name, attribute := range attributes {
+		notification[name] = map[string]interface{}{
+			"Type":  *attribute.DataType,
+			"Value": *attribute.StringValue,
+		}
+	}
+	return notification
+}
+
+func TestEncodeDecodeBody(t *testing.T) {
+	provider, err := NewStaticKeyProvider("k1", bytes.Repeat([]byte{7}, dataKeySize))
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider failed: %s", err.Error())
+	}
+
+	gom := &Gommunicator{metrics: noopMetrics{}}
+	gom.SetCompression(GzipCompressor, 16).SetEncryption(provider, 0)
+
+	body := []byte(`{"data":"` + strings.Repeat("compressible ", 100) + `"}`)
+	attributes := map[string]*sns.MessageAttributeValue{}
+
+	message, err := gom.encodeBody(body, attributes)
+	if err != nil {
+		t.Fatalf("encodeBody failed: %s", err.Error())
+	}
+
+	if strings.Contains(message, "compressible") {
+		t.Fatalf("encodeBody leaked the plaintext body")
+	}
+
+	if attributes["ContentEncoding"] == nil || attributes["EncryptionKeyID"] == nil {
+		t.Fatalf("encodeBody did not set the encoding attributes")
+	}
+
+	decoded, err := gom.decodeBody(message, notificationAttributes(attributes))
+	if err != nil {
+		t.Fatalf("decodeBody failed: %s", err.Error())
+	}
+
+	if !bytes.Equal(decoded, body) {
+		t.Fatalf("decodeBody returned a different body")
+	}
+}
+
+// sizeMetrics records the sizes reported by MessageSize
+type sizeMetrics struct {
+	noopMetrics
+	lock  sync.Mutex
+	sizes [][2]int
+}
+
+func (metrics *sizeMetrics) MessageSize(raw, compressed int) {
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	metrics.sizes = append(metrics.sizes, [2]int{raw, compressed})
+}
+
+func TestCompressionSizes(t *testing.T) {
+	var logs bytes.Buffer
+	metrics := new(sizeMetrics)
+
+	cluster := newFakeCluster()
+	orders := cluster.service("orders").
+		SetCompression(GzipCompressor, 64).
+		SetMetrics(metrics).
+		SetLogState(true).
+		SetLogger(NewJSONLogger(&logs, LevelInfo))
+	stock := cluster.service("stock")
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, "ok")
+	})
+
+	receiver, err := orders.Exec(&ExecInput{
+		DataTransactionID: "dt-1",
+		Service:           "stock",
+		Action:            "stock.reserve",
+		Payload:           strings.Repeat("sku-1 ", 200),
+		Timeout:           2,
+	})
+	if err != nil {
+		t.Fatalf("Exec failed: %s", err.Error())
+	}
+
+	if response := <-receiver; response == nil || !response.Success {
+		t.Fatalf("expected a successful response, got %+v", response)
+	}
+
+	if encoding := cluster.publications()[0]["ContentEncoding"]; encoding != "gzip" {
+		t.Fatalf("expected the request to be compressed, got encoding %q", encoding)
+	}
+
+	metrics.lock.Lock()
+	sizes := metrics.sizes
+	metrics.lock.Unlock()
+	if len(sizes) != 1 || sizes[0][1] >= sizes[0][0] {
+		t.Fatalf("expected a compressed size smaller than the raw size, got %v", sizes)
+	}
+
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]interface{}
+		json.Unmarshal([]byte(line), &entry)
+		if entry["msg"] != "Message compressed" {
+			continue
+		}
+
+		if entry[FieldAction] != "stock.reserve" || entry["bytes"] != float64(sizes[0][0]) || entry["compressed_bytes"] != float64(sizes[0][1]) {
+			t.Fatalf("expected the compression log to report the sizes, got %v", entry)
+		}
+		return
+	}
+
+	t.Fatalf("expected the compression to be logged, got %s", logs.String())
+}
+
+func TestSignVerify(t *testing.T) {
+	secret := []byte("billing-secret")
+	sender := &Gommunicator{ServiceName: "billing"}
+	sender.SetSigning(NewHMACSigningKey(secret), nil, 0)
+
+	receiver := &Gommunicator{ServiceName: "accounts"}
+	receiver.SetSigning(nil, NewKeyRing().AddHMAC("billing", secret), 0)
+
+	attributes := map[string]*sns.MessageAttributeValue{
+		"Service": stringAttribute("accounts"),
+		"Action":  stringAttribute("accounts.debit"),
+	}
+
+	if err := sender.sign("message", attributes); err != nil {
+		t.Fatalf("sign failed: %s", err.Error())
+	}
+
+	from, err := receiver.verify("message", notificationAttributes(attributes))
+	if err != nil || from != "billing" {
+		t.Fatalf("verify failed: %v", err)
+	}
+
+	if _, err := receiver.verify("tampered", notificationAttributes(attributes)); err == nil {
+		t.Fatalf("verify accepted a tampered message")
+	}
+
+	for name, value := range map[string]string{"Action": "accounts.close", HeaderTenantID: "other", "traceparent": "00-forged"} {
+		tampered := notificationAttributes(attributes)
+		tampered[name] = map[string]interface{}{"Type": "String", "Value": value}
+		if _, err := receiver.verify("message", tampered); err == nil {
+			t.Fatalf("verify accepted a message with a tampered %s attribute", name)
+		}
+	}
+}
+
+func TestEd25519SigningKey(t *testing.T) {
+	if _, err := NewEd25519SigningKey(ed25519.PrivateKey("short")); err == nil {
+		t.Fatalf("expected a short private key to be rejected")
+	}
+
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	key, err := NewEd25519SigningKey(privateKey)
+	if err != nil {
+		t.Fatalf("NewEd25519SigningKey failed: %s", err.Error())
+	}
+
+	signature, _ := key.Sign([]byte("message"))
+	if err := NewKeyRing().AddEd25519("billing", publicKey).Verify("billing", Ed25519, []byte("message"), signature); err != nil {
+		t.Fatalf("Verify failed: %s", err.Error())
+	}
+
+	// A misconfigured public key rejects the messages instead of panicking
+	err = NewKeyRing().AddEd25519("billing", publicKey[:16]).Verify("billing", Ed25519, []byte("message"), signature)
+	if !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for a short public key, got %v", err)
+	}
+}
+
+func TestResponseSender(t *testing.T) {
+	actionID := "action-1"
+	called := false
+	registerCallback(actionID, "payments", "payments.charge", func(response *DataTransactionResponse) error {
+		called = true
+		return nil
+	})
+	defer deleteCallback(actionID)
+
+	response := &DataTransactionResponse{ActionID: &actionID}
+	if err := callCallback(response, "stock"); !errors.Is(err, ErrInvalidSignature) || called {
+		t.Fatalf("expected a response from another service to be rejected, got %v", err)
+	}
+
+	if err := callCallback(response, "payments"); err != nil || !called {
+		t.Fatalf("expected the response of the requested service to be delivered, got %v", err)
+	}
+}
+
+func TestPackAttributes(t *testing.T) {
+	attributes := map[string]*sns.MessageAttributeValue{
+		"Service":     stringAttribute("stock"),
+		"Action":      stringAttribute("stock.reserve"),
+		"ContentType": stringAttribute("application/msgpack"),
+		"tracestate":  stringAttribute("k=v"),
+	}
+
+	if err := packAttributes(attributes); err != nil || len(attributes) != 4 {
+		t.Fatalf("expected attributes under the limit to be kept apart, got %d: %v", len(attributes), err)
+	}
+
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		attributes[name] = stringAttribute(name)
+	}
+
+	if err := packAttributes(attributes); err != nil {
+		t.Fatalf("packAttributes failed: %s", err.Error())
+	}
+
+	if len(attributes) != 10 || attributes["tracestate"] != nil || attributes[packedAttribute] == nil {
+		t.Fatalf("expected the transport attributes to be packed, got %v", attributeValues(attributes))
+	}
+
+	notification := notificationAttributes(attributes)
+	if err := unpackAttributes(notification); err != nil {
+		t.Fatalf("unpackAttributes failed: %s", err.Error())
+	}
+
+	if messageAttribute(notification, "tracestate") != "k=v" || messageAttribute(notification, "ContentType") != "application/msgpack" {
+		t.Fatalf("expected the packed attributes back, got %v", notification)
+	}
+
+	attributes["h"] = stringAttribute("h")
+	attributes["i"] = stringAttribute("i")
+	attributes["j"] = stringAttribute("j")
+	if err := packAttributes(attributes); !errors.Is(err, ErrTooManyAttributes) {
+		t.Fatalf("expected ErrTooManyAttributes, got %v", err)
+	}
+}
+
+func TestReservedAttributeHeaders(t *testing.T) {
+	var reported []error
+	gom := NewGommunicator(nil, nil, nil, "", "orders", "", "").SetLogState(false)
+	gom.SetErrorHandler(func(err error) { reported = append(reported, err) })
+
+	for _, header := range []string{"Service", "Action", "Signature", "Sender", "ContentType", packedAttribute} {
+		gom.SetAttributeHeaders(HeaderUserID, header)
+	}
+
+	if len(reported) != 6 || !errors.Is(reported[0], ErrReservedAttribute) {
+		t.Fatalf("expected every reserved header to be rejected, got %v", reported)
+	}
+
+	if len(gom.attributeHeaders) != 1 || gom.attributeHeaders[0] != HeaderTenantID {
+		t.Fatalf("expected the attribute headers to be kept, got %v", gom.attributeHeaders)
+	}
+
+	gom.SetAttributeHeaders(HeaderTenantID, "region")
+	if len(reported) != 6 || len(gom.attributeHeaders) != 2 {
+		t.Fatalf("expected the headers to be set, got %v and %v", gom.attributeHeaders, reported)
+	}
+}
+
+func TestAllFeaturesAttributes(t *testing.T) {
+	provider, err := NewStaticKeyProvider("k1", bytes.Repeat([]byte{7}, dataKeySize))
+	if err != nil {
+		t.Fatalf("NewStaticKeyProvider failed: %s", err.Error())
+	}
+
+	ring := NewKeyRing().AddHMAC("orders", []byte("orders-secret")).AddHMAC("stock", []byte("stock-secret"))
+	tracing := sdktrace.NewTracerProvider()
+	headers := map[string]string{HeaderTenantID: "acme", HeaderUserID: "ana", HeaderLocale: "pt-BR", "region": "eu"}
+
+	cluster := newFakeCluster()
+	services := make(map[string]*Gommunicator)
+	for _, name := range []string{"orders", "stock"} {
+		services[name] = cluster.service(name).
+			SetCodec(jsonTextCodec{}).
+			SetCompression(GzipCompressor, 16).
+			SetEncryption(provider, 0).
+			SetSigning(NewHMACSigningKey([]byte(name+"-secret")), ring, 0).
+			SetTracerProvider(tracing).
+			SetAttributeHeaders(HeaderTenantID, HeaderUserID, HeaderLocale, "region")
+	}
+
+	stock := services["stock"]
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		var input map[string]string
+		if err := request.Decode(&input); err != nil {
+			return err
+		}
+		return stock.Respond(request, map[string]string{"reserved": input["sku"]})
+	})
+
+	// A remote parent with a trace state, so both traceparent and tracestate are sent
+	state, _ := trace.ParseTraceState("vendor=value")
+	parent := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+		TraceState: state,
+	}))
+
+	receiver, err := services["orders"].Exec(&ExecInput{
+		DataTransactionID: "dt-1",
+		Service:           "stock",
+		Action:            "stock.reserve",
+		Payload:           map[string]string{"sku": strings.Repeat("sku-1 ", 20)},
+		Headers:           headers,
+		Context:           parent,
+		Timeout:           2,
+	})
+	if err != nil {
+		t.Fatalf("Exec failed: %s", err.Error())
+	}
+
+	response := <-receiver
+	if response == nil || !response.Success {
+		t.Fatalf("expected a successful response, got %+v", response)
+	}
+
+	publications := cluster.publications()
+	if len(publications) != 2 {
+		t.Fatalf("expected a request and a response, got %d messages", len(publications))
+	}
+
+	for _, attributes := range publications {
+		if attributes[packedAttribute] == "" || attributes[HeaderTenantID] != "acme" || attributes["Service"] == "" {
+			t.Fatalf("expected the transport attributes packed and the routing ones apart, got %v", attributes)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// FieldError describes a field failing validation
+type FieldError struct {
+	Field   string `json:"field"`   // Path of the field (customer.address.zip, items[0].sku)
+	Rule    string `json:"rule"`    // Rule violated (required, min, type...)
+	Message string `json:"message"` // Human readable message
+}
+
+// Validator validates the data of requests or responses
+// decode decodes the data into the given pointer, the same way Decode does
+type Validator interface {
+	Validate(decode func(target interface{}) error) []FieldError
+}
+
+// SchemaValidator is a Validator backed by a JSON schema
+// Its schema is listed on the service description
+type SchemaValidator interface {
+	Validator
+	Schema() json.RawMessage
+}
+
+type actionValidators struct {
+	input  Validator
+	output Validator
+}
+
+// validatorStore holds the validators per registered action
+type validatorStore struct {
+	lock       sync.RWMutex
+	validators map[string]actionValidators
+}
+
+func newValidatorStore() *validatorStore {
+	return &validatorStore{validators: make(map[string]actionValidators)}
+}
+
+func (store *validatorStore) update(action string, update func(*actionValidators)) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	validators := store.validators[action]
+	update(&validators)
+	store.validators[action] = validators
+}
+
+func (store *validatorStore) get(action string) actionValidators {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+	return store.validators[action]
+}
+
+// SetInputValidator validates the requests of an action before its handler runs
+// Invalid requests are answered with a ValidationError listing the field errors
+func (gom *Gommunicator) SetInputValidator(action string, validator Validator) *Gommunicator {
+	gom.validators.update(action, func(validators *actionValidators) {
+		validators.input = validator
+	})
+
+	if schema, ok := validator.(SchemaValidator); ok {
+		schemas := gom.schemas.get(action)
+		schemas.input = schema.Schema()
+		gom.schemas.set(action, schemas)
+	}
+
+	return gom
+}
+
+// SetOutputValidator validates the responses of an action, only in dev mode
+// Invalid responses are still sent, the field errors are logged
+func (gom *Gommunicator) SetOutputValidator(action string, validator Validator) *Gommunicator {
+	gom.validators.update(action, func(validators *actionValidators) {
+		validators.output = validator
+	})
+
+	if schema, ok := validator.(SchemaValidator); ok {
+		schemas := gom.schemas.get(action)
+		schemas.output = schema.Schema()
+		gom.schemas.set(action, schemas)
+	}
+
+	return gom
+}
+
+// SetDevMode enables development checks such as output validation
+func (gom *Gommunicator) SetDevMode(state bool) *Gommunicator {
+	gom.devMode = state
+	return gom
+}
+
+// routeAction returns the registered action serving a request action
+func (gom *Gommunicator) routeAction(action string) string {
+	if rt, ok := gom.actions.lookup(action); ok {
+		return rt.action
+	}
+
+	return action
+}
+
+// validateRequest validates a request against the input validator of its action
+func (gom *Gommunicator) validateRequest(request *DataTransactionRequest) MapErr {
+	validator := gom.validators.get(gom.routeAction(request.Action)).input
+	if validator == nil {
+		return nil
+	}
+
+	fields := validator.Validate(request.Decode)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	return NewValidationError(fmt.Sprintf("invalid request to %s", request.Action), fields)
+}
+
+// validateResponse validates, in dev mode, a response payload against the output validator of the action
+func (gom *Gommunicator) validateResponse(request *DataTransactionRequest, payload interface{}) {
+	if !gom.devMode {
+		return
+	}
+
+	validator := gom.validators.get(gom.routeAction(request.Action)).output
+	if validator == nil {
+		return
+	}
+
+	fields := validator.Validate(func(target interface{}) error {
+		return decode(payload, target)
+	})
+
+	for _, field := range fields {
+		gom.tryLogErr("Invalid response", append(requestFields(request), F("field", field.Field), F("reason", field.Message))...)
+	}
+}
@@ -0,0 +1,69 @@
+package gommunicator
+
+import "strings"
+
+// Validation error code
+const Validation SimpleErrorCode = "VALIDATION"
+
+// ValidationError structure
+// Its context lists the field errors under the "fields" key
+type ValidationError struct {
+	Message string
+	Fields  []FieldError
+	Cause   error
+}
+
+// NewValidationError returns a new ValidationError
+func NewValidationError(message string, fields []FieldError) *ValidationError {
+	return &ValidationError{
+		Message: message,
+		Fields:  fields,
+	}
+}
+
+// GetMessage returns the validation error message followed by its field errors
+func (err *ValidationError) GetMessage() string {
+	lines := []string{err.Message}
+	for _, field := range err.Fields {
+		lines = append(lines, field.Field+": "+field.Message)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// GetCode returns the validation error code
+func (err *ValidationError) GetCode() string {
+	return string(Validation)
+}
+
+// GetType returns the validation error ErrType
+func (err *ValidationError) GetType() ErrType {
+	return ValidationErrorType
+}
+
+// GetContext returns the field errors
+func (err *ValidationError) GetContext() map[string]interface{} {
+	return map[string]interface{}{
+		"fields": err.Fields,
+	}
+}
+
+func (err *ValidationError) Error() string {
+	return err.GetMessage()
+}
+
+// Unwrap returns the original Go error, if any
+func (err *ValidationError) Unwrap() error {
+	return err.Cause
+}
+
+// Retryable returns false, the same data fails again
+func (err *ValidationError) Retryable() bool {
+	return false
+}
+
+// Is reports if target is a MapErr with the validation code
+func (err *ValidationError) Is(target error) bool {
+	mapErr, ok := target.(MapErr)
+	return ok && mapErr.GetCode() == err.GetCode()
+}
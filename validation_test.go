@@ -0,0 +1,55 @@
+package gommunicator
+
+import (
+	"errors"
+	"testing"
+)
+
+// requiredValidator requires the given keys on the data
+type requiredValidator []string
+
+func (keys requiredValidator) Validate(decode func(target interface{}) error) []FieldError {
+	data := make(map[string]interface{})
+	if err := decode(&data); err != nil {
+		return []FieldError{{Rule: "decode", Message: err.Error()}}
+	}
+
+	var fields []FieldError
+	for _, key := range keys {
+		if _, ok := data[key]; !ok {
+			fields = append(fields, FieldError{Field: key, Rule: "required", Message: key + " is required"})
+		}
+	}
+	return fields
+}
+
+func TestInvalidRequest(t *testing.T) {
+	cluster := newFakeCluster()
+	orders := cluster.service("orders")
+	stock := cluster.service("stock")
+
+	handled := 0
+	stock.RegisterAction("stock.reserve", func(request *DataTransactionRequest) error {
+		handled++
+		return stock.Respond(request, "reserved")
+	}).SetInputValidator("stock.reserve", requiredValidator{"sku", "quantity"})
+
+	receiver, err := orders.Exec(&ExecInput{DataTransactionID: "dt-1", Service: "stock", Action: "stock.reserve", Payload: map[string]int{"quantity": 2}, Timeout: 2})
+	if err != nil {
+		t.Fatalf("Exec failed: %s", err.Error())
+	}
+
+	response := <-receiver
+	if response == nil || response.Success || handled != 0 {
+		t.Fatalf("expected the request to be refused before its handler, got %+v", response)
+	}
+
+	var validationErr *ValidationError
+	if !errors.As(response.Err(), &validationErr) {
+		t.Fatalf("expected a ValidationError, got %v", response.Err())
+	}
+
+	if len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "sku" || validationErr.Fields[0].Rule != "required" {
+		t.Fatalf("expected the missing sku to be reported, got %+v", validationErr.Fields)
+	}
+}
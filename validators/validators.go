@@ -0,0 +1,188 @@
+// Package validators provides gommunicator request validators
+//
+// Validate with a JSON schema, which is also listed on the service description:
+//
+//	validator, err := validators.JSONSchema(`{"type": "object", "required": ["email"]}`)
+//	gom.SetInputValidator("users.create", validator)
+//
+// Or with the validate tags of a struct:
+//
+//	gom.SetInputValidator("users.create", validators.Struct(CreateUser{}))
+package validators
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/kelvne/gommunicator"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+func decodeError(err error) []gommunicator.FieldError {
+	return []gommunicator.FieldError{
+		{
+			Rule:    "decode",
+			Message: err.Error(),
+		},
+	}
+}
+
+type schemaValidator struct {
+	raw    json.RawMessage
+	schema *jsonschema.Schema
+}
+
+// JSONSchema returns a validator checking data against a JSON schema
+func JSONSchema(schema string) (gommunicator.SchemaValidator, error) {
+	compiled, err := jsonschema.CompileString("schema.json", schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return &schemaValidator{
+		raw:    json.RawMessage(schema),
+		schema: compiled,
+	}, nil
+}
+
+func (validator *schemaValidator) Schema() json.RawMessage {
+	return validator.raw
+}
+
+// instancePath turns a JSON pointer (/items/0/sku) into a field path (items[0].sku)
+func instancePath(pointer string) string {
+	path := ""
+	for _, segment := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+
+		segment = strings.NewReplacer("~1", "/", "~0", "~").Replace(segment)
+		if _, err := strconv.Atoi(segment); err == nil {
+			path += "[" + segment + "]"
+			continue
+		}
+
+		if path != "" {
+			path += "."
+		}
+		path += segment
+	}
+
+	return path
+}
+
+// keyword returns the last keyword of a schema location (#/properties/email/format -> format)
+func keyword(location string) string {
+	return location[strings.LastIndex(location, "/")+1:]
+}
+
+func flatten(err *jsonschema.ValidationError, fields []gommunicator.FieldError) []gommunicator.FieldError {
+	if len(err.Causes) == 0 {
+		return append(fields, gommunicator.FieldError{
+			Field:   instancePath(err.InstanceLocation),
+			Rule:    keyword(err.KeywordLocation),
+			Message: err.Message,
+		})
+	}
+
+	for _, cause := range err.Causes {
+		fields = flatten(cause, fields)
+	}
+
+	return fields
+}
+
+func (validator *schemaValidator) Validate(decode func(target interface{}) error) []gommunicator.FieldError {
+	var data interface{}
+	if err := decode(&data); err != nil {
+		return decodeError(err)
+	}
+
+	err := validator.schema.Validate(data)
+	if err == nil {
+		return nil
+	}
+
+	var validationErr *jsonschema.ValidationError
+	if errors.As(err, &validationErr) {
+		return flatten(validationErr, make([]gommunicator.FieldError, 0))
+	}
+
+	return decodeError(err)
+}
+
+var validate = newValidate()
+
+func newValidate() *validator.Validate {
+	validate := validator.New()
+
+	// Report JSON field names instead of Go ones
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	return validate
+}
+
+type structValidator struct {
+	structType reflect.Type
+}
+
+// Struct returns a validator decoding data into the prototype struct type and checking its validate tags
+func Struct(prototype interface{}) gommunicator.Validator {
+	structType := reflect.TypeOf(prototype)
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	return &structValidator{structType: structType}
+}
+
+// namespacePath drops the struct name from a validator namespace (CreateUser.address.zip -> address.zip)
+func namespacePath(namespace string) string {
+	if idx := strings.Index(namespace, "."); idx >= 0 {
+		return namespace[idx+1:]
+	}
+
+	return namespace
+}
+
+func (sv *structValidator) Validate(decode func(target interface{}) error) []gommunicator.FieldError {
+	target := reflect.New(sv.structType).Interface()
+	if err := decode(target); err != nil {
+		return decodeError(err)
+	}
+
+	err := validate.Struct(target)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		return decodeError(err)
+	}
+
+	fields := make([]gommunicator.FieldError, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		fields = append(fields, gommunicator.FieldError{
+			Field:   namespacePath(fieldErr.Namespace()),
+			Rule:    fieldErr.Tag(),
+			Message: fieldErr.Error(),
+		})
+	}
+
+	return fields
+}
@@ -0,0 +1,83 @@
+package validators
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/kelvne/gommunicator"
+)
+
+// decoder decodes a JSON document the way requests are decoded
+func decoder(document string) func(target interface{}) error {
+	return func(target interface{}) error {
+		var data interface{}
+		if err := json.Unmarshal([]byte(document), &data); err != nil {
+			return err
+		}
+		return (&gommunicator.DataTransactionRequest{Data: data}).Decode(target)
+	}
+}
+
+// rules maps the failing fields to their rules
+func rules(fields []gommunicator.FieldError) map[string]string {
+	failing := make(map[string]string, len(fields))
+	for _, field := range fields {
+		failing[field.Field] = field.Rule
+	}
+	return failing
+}
+
+func TestJSONSchema(t *testing.T) {
+	validator, err := JSONSchema(`{
+		"type": "object",
+		"required": ["email", "items"],
+		"properties": {
+			"email": {"type": "string", "format": "email"},
+			"items": {"type": "array", "items": {"type": "object", "required": ["sku"], "properties": {"sku": {"type": "string"}, "quantity": {"minimum": 1}}}}
+		}
+	}`)
+	if err != nil {
+		t.Fatalf("JSONSchema failed: %s", err.Error())
+	}
+
+	if fields := validator.Validate(decoder(`{"email": "ana@example.com", "items": [{"sku": "a", "quantity": 1}]}`)); len(fields) != 0 {
+		t.Fatalf("expected valid data, got %v", fields)
+	}
+
+	failing := rules(validator.Validate(decoder(`{"items": [{"sku": "a"}, {"quantity": 0}]}`)))
+	if len(failing) != 3 || failing[""] != "required" || failing["items[1]"] != "required" || failing["items[1].quantity"] != "minimum" {
+		t.Fatalf("expected the missing email and the second item to fail, got %v", failing)
+	}
+
+	if _, err := JSONSchema(`{"type": 1}`); err == nil {
+		t.Fatalf("expected an invalid schema to be rejected")
+	}
+}
+
+type address struct {
+	Zip string `json:"zip" validate:"required,len=5"`
+}
+
+type createUser struct {
+	Email   string  `json:"email" validate:"required,email"`
+	Age     int     `json:"age" validate:"gte=18"`
+	Address address `json:"address"`
+}
+
+func TestStruct(t *testing.T) {
+	validator := Struct(&createUser{})
+
+	if fields := validator.Validate(decoder(`{"email": "ana@example.com", "age": 30, "address": {"zip": "12345"}}`)); len(fields) != 0 {
+		t.Fatalf("expected valid data, got %v", fields)
+	}
+
+	failing := rules(validator.Validate(decoder(`{"email": "ana", "age": 12, "address": {"zip": "1"}}`)))
+	if len(failing) != 3 || failing["email"] != "email" || failing["age"] != "gte" || failing["address.zip"] != "len" {
+		t.Fatalf("expected the fields to fail with their JSON names, got %v", failing)
+	}
+
+	failing = rules(validator.Validate(decoder(`{"age": "old"}`)))
+	if failing[""] != "decode" {
+		t.Fatalf("expected a decode failure, got %v", failing)
+	}
+}
@@ -0,0 +1,505 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkflowStatus is the progress of a workflow run
+type WorkflowStatus string
+
+// Workflow statuses
+const (
+	WorkflowRunning   WorkflowStatus = "RUNNING"
+	WorkflowSucceeded WorkflowStatus = "SUCCEEDED"
+	WorkflowFailed    WorkflowStatus = "FAILED"
+)
+
+// NodeStatus is the progress of a workflow node
+type NodeStatus string
+
+// Workflow node statuses
+const (
+	NodePending   NodeStatus = "PENDING"
+	NodeRunning   NodeStatus = "RUNNING"
+	NodeSucceeded NodeStatus = "SUCCEEDED"
+	NodeFailed    NodeStatus = "FAILED"
+	NodeSkipped   NodeStatus = "SKIPPED" // Not run because the workflow failed first
+)
+
+// ErrWorkflowNotFound is returned when a workflow definition or run is unknown
+var ErrWorkflowNotFound = errors.New("workflow not found")
+
+// WorkflowNode is an action call of a workflow, run once all the nodes it depends on succeeded
+// Input builds the request from the workflow run, the workflow input is sent when nil
+// Failed calls are attempted again Retries times, unless the error is a MapErr that is not retryable
+// A timed out call may still have been handled, so timeouts are retried only with RetryTimeouts,
+// set it when the action is idempotent
+type WorkflowNode struct {
+	Name          string
+	Service       string
+	Action        string
+	DependsOn     []string
+	Input         func(run *WorkflowRun) (interface{}, error)
+	Timeout       int
+	Retries       int
+	RetryDelay    time.Duration // Grows with every attempt, 1 second by default
+	RetryTimeouts bool
+}
+
+// Workflow is a DAG of action calls run as a single data transaction, independent nodes run in parallel
+// Output builds the workflow response, by default the outputs of the nodes no other node depends on
+type Workflow struct {
+	Name   string
+	Nodes  []*WorkflowNode
+	Output func(run *WorkflowRun) (interface{}, error)
+}
+
+// NodeState is the persisted progress of a workflow node
+type NodeState struct {
+	Status     NodeStatus      `json:"status"`
+	Attempts   int             `json:"attempts"`
+	Output     json.RawMessage `json:"output,omitempty"` // Response data as JSON
+	Error      string          `json:"error,omitempty"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// WorkflowRun is the persisted progress of a workflow, keyed by its name and DataTransactionID
+type WorkflowRun struct {
+	DataTransactionID string                `json:"dataTransactionId"`
+	Workflow          string                `json:"workflow"`
+	Status            WorkflowStatus        `json:"status"`
+	Input             json.RawMessage       `json:"input,omitempty"`
+	Nodes             map[string]*NodeState `json:"nodes"`
+	Error             string                `json:"error,omitempty"`
+	StartedAt         time.Time             `json:"startedAt"`
+	UpdatedAt         time.Time             `json:"updatedAt"`
+
+	lock    sync.RWMutex
+	failure error // Error of the first failed node
+}
+
+// Decode decodes the workflow input into incoming
+func (run *WorkflowRun) Decode(incoming interface{}) error {
+	return decode(run.Input, incoming)
+}
+
+// Output decodes the response data of a succeeded node into incoming
+func (run *WorkflowRun) Output(node string, incoming interface{}) error {
+	run.lock.RLock()
+	state, ok := run.Nodes[node]
+	var output json.RawMessage
+	if ok && state.Status == NodeSucceeded {
+		output = state.Output
+	}
+	run.lock.RUnlock()
+
+	if output == nil {
+		return fmt.Errorf("workflow node %s has no output", node)
+	}
+
+	return decode(output, incoming)
+}
+
+type workflowRegistry struct {
+	lock      sync.RWMutex
+	workflows map[string]*Workflow
+	handling  map[string]bool // Runs requested to this process being handled
+	store     WorkflowStore
+}
+
+func newWorkflowRegistry() *workflowRegistry {
+	return &workflowRegistry{
+		workflows: make(map[string]*Workflow),
+		handling:  make(map[string]bool),
+		store:     NewMemoryWorkflowStore(),
+	}
+}
+
+// claim marks a run as handled, false when it already is
+func (registry *workflowRegistry) claim(workflow, dtID string) bool {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	key := workflowKey(workflow, dtID)
+	if registry.handling[key] {
+		return false
+	}
+
+	registry.handling[key] = true
+	return true
+}
+
+func (registry *workflowRegistry) release(workflow, dtID string) {
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+	delete(registry.handling, workflowKey(workflow, dtID))
+}
+
+func (registry *workflowRegistry) get(name string) (*Workflow, bool) {
+	registry.lock.RLock()
+	defer registry.lock.RUnlock()
+	workflow, ok := registry.workflows[name]
+	return workflow, ok
+}
+
+// validateWorkflow checks node names are unique, dependencies known and acyclic
+func validateWorkflow(workflow *Workflow) error {
+	if workflow.Name == "" || len(workflow.Nodes) == 0 {
+		return errors.New("workflow needs a name and nodes")
+	}
+
+	waiting := make(map[string]int, len(workflow.Nodes))
+	dependents := make(map[string][]string)
+	for _, node := range workflow.Nodes {
+		if _, ok := waiting[node.Name]; ok || node.Name == "" {
+			return fmt.Errorf("workflow %s: invalid or duplicated node name %q", workflow.Name, node.Name)
+		}
+		waiting[node.Name] = len(node.DependsOn)
+	}
+
+	ready := make([]string, 0)
+	for _, node := range workflow.Nodes {
+		for _, dependency := range node.DependsOn {
+			if _, ok := waiting[dependency]; !ok {
+				return fmt.Errorf("workflow %s: node %s depends on unknown node %s", workflow.Name, node.Name, dependency)
+			}
+			dependents[dependency] = append(dependents[dependency], node.Name)
+		}
+
+		if len(node.DependsOn) == 0 {
+			ready = append(ready, node.Name)
+		}
+	}
+
+	visited := 0
+	for len(ready) > 0 {
+		name := ready[0]
+		ready = ready[1:]
+		visited++
+
+		for _, dependent := range dependents[name] {
+			waiting[dependent]--
+			if waiting[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+
+	if visited != len(workflow.Nodes) {
+		return fmt.Errorf("workflow %s: nodes have a dependency cycle", workflow.Name)
+	}
+
+	return nil
+}
+
+// RegisterWorkflow registers a workflow and an action named after it running the workflow
+// Callers Exec the action like any other, its timeout must cover the whole workflow
+func (gom *Gommunicator) RegisterWorkflow(workflow *Workflow) *Gommunicator {
+	if err := validateWorkflow(workflow); err != nil {
+		gom.onErr(err)
+		return gom
+	}
+
+	gom.workflows.lock.Lock()
+	gom.workflows.workflows[workflow.Name] = workflow
+	gom.workflows.lock.Unlock()
+
+	return gom.RegisterAction(workflow.Name, gom.workflowHandler(workflow))
+}
+
+// SetWorkflowStore sets where workflow runs are persisted, in memory by default
+func (gom *Gommunicator) SetWorkflowStore(store WorkflowStore) *Gommunicator {
+	gom.workflows.store = store
+	return gom
+}
+
+// WorkflowRun returns the persisted run of a workflow as dtID
+func (gom *Gommunicator) WorkflowRun(workflow, dtID string) (*WorkflowRun, error) {
+	run, err := gom.workflows.store.Load(workflow, dtID)
+	if err != nil {
+		return nil, err
+	}
+
+	if run == nil {
+		return nil, ErrWorkflowNotFound
+	}
+
+	return run, nil
+}
+
+// RunWorkflow runs a registered workflow as the data transaction dtID and waits for it
+// The first failed node stops scheduling new nodes, the returned run tells how it ended
+func (gom *Gommunicator) RunWorkflow(name, dtID string, input interface{}) (*WorkflowRun, error) {
+	workflow, ok := gom.workflows.get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrWorkflowNotFound, name)
+	}
+
+	raw, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	run := &WorkflowRun{
+		DataTransactionID: dtID,
+		Workflow:          name,
+		Status:            WorkflowRunning,
+		Input:             raw,
+		Nodes:             make(map[string]*NodeState, len(workflow.Nodes)),
+		StartedAt:         now,
+		UpdatedAt:         now,
+	}
+
+	for _, node := range workflow.Nodes {
+		run.Nodes[node.Name] = &NodeState{Status: NodePending}
+	}
+
+	return run, gom.executeWorkflow(workflow, run)
+}
+
+// workflowHandler runs the workflow for a request and responds its output
+// The run is keyed by the request DataTransactionID: a request for a finished run is answered with its outcome
+// without running it again, one for a run in progress is rejected with a ConflictError
+func (gom *Gommunicator) workflowHandler(workflow *Workflow) ActionHandler {
+	return func(request *DataTransactionRequest) error {
+		var input interface{}
+		if err := DecodeRequest(request, &input); err != nil {
+			return gom.RespondError(request, NewValidationError("Invalid workflow input", nil))
+		}
+
+		dtID := request.ID
+		if dtID == "" {
+			dtID = uuid.New().String()
+		}
+
+		inProgress := NewConflictError("workflow run", dtID, fmt.Sprintf("workflow %s is already running as %s", workflow.Name, dtID), nil)
+		if !gom.workflows.claim(workflow.Name, dtID) {
+			return gom.RespondError(request, inProgress)
+		}
+		defer gom.workflows.release(workflow.Name, dtID)
+
+		run, err := gom.workflows.store.Load(workflow.Name, dtID)
+		if err != nil {
+			return gom.RespondError(request, NewUnavailableError("workflow store", err))
+		}
+
+		switch {
+		case run == nil:
+			if run, err = gom.RunWorkflow(workflow.Name, dtID, input); err != nil {
+				return gom.RespondError(request, NewUnavailableError("workflow store", err))
+			}
+		case run.Status == WorkflowRunning:
+			// Run by another instance, or left by a crashed one
+			return gom.RespondError(request, inProgress)
+		}
+
+		return gom.respondWorkflowRun(request, workflow, run)
+	}
+}
+
+// respondWorkflowRun answers a request with the outcome of a finished run
+func (gom *Gommunicator) respondWorkflowRun(request *DataTransactionRequest, workflow *Workflow, run *WorkflowRun) error {
+	if run.Status == WorkflowFailed {
+		var mapErr MapErr
+		if errors.As(run.failure, &mapErr) {
+			return gom.RespondError(request, mapErr)
+		}
+		return gom.RespondError(request, NewSimpleError(Basic, run.Error))
+	}
+
+	output, err := workflowOutput(workflow, run)
+	if err != nil {
+		return gom.RespondError(request, NewSimpleError(Basic, err.Error()))
+	}
+
+	return gom.Respond(request, output)
+}
+
+// workflowOutput builds the response of a succeeded run
+func workflowOutput(workflow *Workflow, run *WorkflowRun) (interface{}, error) {
+	if workflow.Output != nil {
+		return workflow.Output(run)
+	}
+
+	leaves := make(map[string]bool, len(workflow.Nodes))
+	for _, node := range workflow.Nodes {
+		leaves[node.Name] = true
+	}
+	for _, node := range workflow.Nodes {
+		for _, dependency := range node.DependsOn {
+			delete(leaves, dependency)
+		}
+	}
+
+	output := make(map[string]json.RawMessage, len(leaves))
+	for name := range leaves {
+		output[name] = run.Nodes[name].Output
+	}
+
+	return output, nil
+}
+
+// saveWorkflow saves a snapshot of the run, so running nodes are not blocked by the store
+func (gom *Gommunicator) saveWorkflow(run *WorkflowRun) error {
+	run.lock.Lock()
+	run.UpdatedAt = time.Now()
+	snapshot := copyWorkflowRun(run)
+	run.lock.Unlock()
+
+	return gom.workflows.store.Save(snapshot)
+}
+
+// readyNode tells whether a pending node has all its dependencies succeeded
+func (run *WorkflowRun) readyNode(node *WorkflowNode) bool {
+	if run.Nodes[node.Name].Status != NodePending {
+		return false
+	}
+
+	for _, dependency := range node.DependsOn {
+		if run.Nodes[dependency].Status != NodeSucceeded {
+			return false
+		}
+	}
+
+	return true
+}
+
+type nodeResult struct {
+	node   *WorkflowNode
+	output json.RawMessage
+	err    error
+}
+
+// executeWorkflow schedules the ready nodes until none is left, errors are persistence errors
+// Only this goroutine changes node statuses, nodes only read the outputs of their dependencies
+func (gom *Gommunicator) executeWorkflow(workflow *Workflow, run *WorkflowRun) error {
+	results := make(chan *nodeResult, len(workflow.Nodes))
+	running := 0
+
+	for {
+		if run.Status == WorkflowRunning {
+			for _, node := range workflow.Nodes {
+				if !run.readyNode(node) {
+					continue
+				}
+
+				now := time.Now()
+				run.lock.Lock()
+				run.Nodes[node.Name].Status = NodeRunning
+				run.Nodes[node.Name].StartedAt = &now
+				run.lock.Unlock()
+
+				running++
+				go func(node *WorkflowNode) {
+					output, err := gom.runWorkflowNode(run, node)
+					results <- &nodeResult{node: node, output: output, err: err}
+				}(node)
+			}
+
+			if err := gom.saveWorkflow(run); err != nil {
+				return err
+			}
+		}
+
+		if running == 0 {
+			break
+		}
+
+		result := <-results
+		running--
+
+		now := time.Now()
+		run.lock.Lock()
+		state := run.Nodes[result.node.Name]
+		state.FinishedAt = &now
+		if result.err != nil {
+			gom.tryLogErr("Workflow node failed", F(FieldDataTransactionID, run.DataTransactionID), F("workflow", workflow.Name), F("node", result.node.Name), errorField(result.err))
+			state.Status = NodeFailed
+			state.Error = result.err.Error()
+			if run.Status == WorkflowRunning {
+				run.Status = WorkflowFailed
+				run.Error = fmt.Sprintf("node %s: %s", result.node.Name, result.err.Error())
+				run.failure = result.err
+			}
+		} else {
+			state.Status = NodeSucceeded
+			state.Output = result.output
+		}
+		run.lock.Unlock()
+
+		if err := gom.saveWorkflow(run); err != nil {
+			return err
+		}
+	}
+
+	run.lock.Lock()
+	for _, state := range run.Nodes {
+		if state.Status == NodePending {
+			state.Status = NodeSkipped
+		}
+	}
+	if run.Status == WorkflowRunning {
+		run.Status = WorkflowSucceeded
+	}
+	run.lock.Unlock()
+
+	return gom.saveWorkflow(run)
+}
+
+// retryableNodeError tells whether a failed node call may be attempted again
+// Remote errors are retried only when retryable, timeouts when the node allows it, transport errors always
+func retryableNodeError(node *WorkflowNode, err error) bool {
+	var timeout *TimeoutError
+	if errors.As(err, &timeout) {
+		return node.RetryTimeouts
+	}
+
+	var mapErr MapErr
+	if errors.As(err, &mapErr) {
+		return IsRetryable(err)
+	}
+
+	return true
+}
+
+// runWorkflowNode calls the action of a node, retrying it, and returns its response data
+func (gom *Gommunicator) runWorkflowNode(run *WorkflowRun, node *WorkflowNode) (json.RawMessage, error) {
+	var payload interface{} = run.Input
+	if node.Input != nil {
+		built, err := node.Input(run)
+		if err != nil {
+			return nil, err
+		}
+		payload = built
+	}
+
+	delay := node.RetryDelay
+	if delay <= 0 {
+		delay = time.Second
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * delay)
+		}
+
+		run.lock.Lock()
+		run.Nodes[node.Name].Attempts++
+		run.lock.Unlock()
+
+		output, err := gom.execAndWait(run.DataTransactionID, node.Service, node.Action, node.Timeout, payload)
+		if err == nil || attempt >= node.Retries || !retryableNodeError(node, err) {
+			return output, err
+		}
+
+		gom.tryLogWarn("Workflow node retried", F(FieldDataTransactionID, run.DataTransactionID), F("workflow", run.Workflow), F("node", node.Name), errorField(err))
+	}
+}
@@ -0,0 +1,140 @@
+package gommunicator
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// WorkflowStore persists workflow runs by workflow name and DataTransactionID
+type WorkflowStore interface {
+	Save(run *WorkflowRun) error
+	// Load returns nil when the run is unknown
+	Load(workflow, dtID string) (*WorkflowRun, error)
+}
+
+func workflowKey(workflow, dtID string) string {
+	return workflow + "#" + dtID
+}
+
+func copyWorkflowRun(run *WorkflowRun) *WorkflowRun {
+	copied := &WorkflowRun{
+		DataTransactionID: run.DataTransactionID,
+		Workflow:          run.Workflow,
+		Status:            run.Status,
+		Input:             run.Input,
+		Nodes:             make(map[string]*NodeState, len(run.Nodes)),
+		Error:             run.Error,
+		StartedAt:         run.StartedAt,
+		UpdatedAt:         run.UpdatedAt,
+	}
+
+	for name, state := range run.Nodes {
+		node := *state
+		copied.Nodes[name] = &node
+	}
+
+	return copied
+}
+
+// MemoryWorkflowStore is an in process WorkflowStore
+type MemoryWorkflowStore struct {
+	lock sync.RWMutex
+	runs map[string]*WorkflowRun
+}
+
+// NewMemoryWorkflowStore returns a new MemoryWorkflowStore
+func NewMemoryWorkflowStore() *MemoryWorkflowStore {
+	return &MemoryWorkflowStore{runs: make(map[string]*WorkflowRun)}
+}
+
+// Save stores a copy of the run
+func (store *MemoryWorkflowStore) Save(run *WorkflowRun) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+	store.runs[workflowKey(run.Workflow, run.DataTransactionID)] = copyWorkflowRun(run)
+	return nil
+}
+
+// Load returns a copy of a run
+func (store *MemoryWorkflowStore) Load(workflow, dtID string) (*WorkflowRun, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	run, ok := store.runs[workflowKey(workflow, dtID)]
+	if !ok {
+		return nil, nil
+	}
+
+	return copyWorkflowRun(run), nil
+}
+
+const workflowKeyPrefix = "workflow#"
+
+// DynamoWorkflowStore is a WorkflowStore backed by a DynamoDB table with a single "id" hash key
+// Runs are saved on every node change, give them a table of their own rather than the dedup table:
+//
+//	gom.SetWorkflowStore(NewDynamoWorkflowStore(dynamo, "workflows"))
+type DynamoWorkflowStore struct {
+	dynamo dynamodbiface.DynamoDBAPI
+	table  string
+}
+
+// NewDynamoWorkflowStore returns a new DynamoWorkflowStore
+func NewDynamoWorkflowStore(dynamo dynamodbiface.DynamoDBAPI, table string) *DynamoWorkflowStore {
+	return &DynamoWorkflowStore{
+		dynamo: dynamo,
+		table:  table,
+	}
+}
+
+// Save stores the run
+func (store *DynamoWorkflowStore) Save(run *WorkflowRun) error {
+	raw, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.dynamo.PutItem(&dynamodb.PutItemInput{
+		Item: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(workflowKeyPrefix + workflowKey(run.Workflow, run.DataTransactionID)),
+			},
+			"status": {
+				S: aws.String(string(run.Status)),
+			},
+			"state": {
+				S: aws.String(string(raw)),
+			},
+		},
+		TableName: aws.String(store.table),
+	})
+
+	return err
+}
+
+// Load returns a run
+func (store *DynamoWorkflowStore) Load(workflow, dtID string) (*WorkflowRun, error) {
+	output, err := store.dynamo.GetItem(&dynamodb.GetItemInput{
+		ConsistentRead: aws.Bool(true),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(workflowKeyPrefix + workflowKey(workflow, dtID)),
+			},
+		},
+		TableName: aws.String(store.table),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if output.Item == nil || output.Item["state"] == nil {
+		return nil, nil
+	}
+
+	run := new(WorkflowRun)
+	return run, json.Unmarshal([]byte(aws.StringValue(output.Item["state"].S)), run)
+}
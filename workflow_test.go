@@ -0,0 +1,231 @@
+package gommunicator
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunWorkflow(t *testing.T) {
+	var registerErr error
+	gom := NewGommunicator(nil, nil, nil, "", "checkout", "", "").SetLogState(false).SetErrorHandler(func(err error) {
+		registerErr = err
+	})
+
+	gom.RegisterWorkflow(&Workflow{
+		Name: "cyclic",
+		Nodes: []*WorkflowNode{
+			{Name: "a", DependsOn: []string{"b"}},
+			{Name: "b", DependsOn: []string{"a"}},
+		},
+	})
+	if registerErr == nil {
+		t.Fatalf("expected cyclic workflows to be rejected")
+	}
+
+	outOfStock := NewConflictError("stock", "sku-1", "out of stock", nil)
+	gom.RegisterWorkflow(&Workflow{
+		Name: "orders.place",
+		Nodes: []*WorkflowNode{
+			{
+				Name: "validate",
+				Input: func(run *WorkflowRun) (interface{}, error) {
+					return nil, outOfStock
+				},
+			},
+			{Name: "reserve", Service: "stock", Action: "stock.reserve", DependsOn: []string{"validate"}},
+			{Name: "charge", Service: "payments", Action: "payments.charge", DependsOn: []string{"validate"}},
+			{Name: "ship", Service: "shipping", Action: "shipping.create", DependsOn: []string{"reserve", "charge"}},
+		},
+	})
+
+	run, err := gom.RunWorkflow("orders.place", "dt-1", map[string]string{"sku": "sku-1"})
+	if err != nil {
+		t.Fatalf("RunWorkflow failed: %s", err.Error())
+	}
+
+	if run.Status != WorkflowFailed || !errors.Is(run.failure, outOfStock) {
+		t.Fatalf("expected the run to fail on validate, got %s: %s", run.Status, run.Error)
+	}
+
+	stored, err := gom.WorkflowRun("orders.place", "dt-1")
+	if err != nil {
+		t.Fatalf("WorkflowRun failed: %s", err.Error())
+	}
+
+	if stored.Nodes["validate"].Status != NodeFailed || stored.Nodes["validate"].Attempts != 0 {
+		t.Fatalf("expected validate to fail before any call, got %+v", stored.Nodes["validate"])
+	}
+
+	for _, name := range []string{"reserve", "charge", "ship"} {
+		if stored.Nodes[name].Status != NodeSkipped {
+			t.Fatalf("expected %s to be skipped, got %s", name, stored.Nodes[name].Status)
+		}
+	}
+
+	if _, err := gom.RunWorkflow("unknown", "dt-2", nil); !errors.Is(err, ErrWorkflowNotFound) {
+		t.Fatalf("expected ErrWorkflowNotFound, got %v", err)
+	}
+}
+
+func TestWorkflowExecution(t *testing.T) {
+	cluster := newFakeCluster()
+	checkout := cluster.service("checkout")
+	stock := cluster.service("stock")
+	payments := cluster.service("payments")
+
+	// reserve and charge only respond once both are handled, so they must run in parallel
+	var arrived sync.WaitGroup
+	arrived.Add(2)
+	parallel := func(gom *Gommunicator, output interface{}) ActionHandler {
+		return func(request *DataTransactionRequest) error {
+			arrived.Done()
+			arrived.Wait()
+			return gom.Respond(request, output)
+		}
+	}
+	stock.RegisterAction("stock.reserve", parallel(stock, map[string]string{"reservation": "r-1"}))
+	payments.RegisterAction("payments.charge", parallel(payments, map[string]string{"charge": "c-1"}))
+
+	var shipCalls int32
+	stock.RegisterAction("stock.ship", func(request *DataTransactionRequest) error {
+		if atomic.AddInt32(&shipCalls, 1) == 1 {
+			return stock.RespondError(request, NewUnavailableError("carrier", nil))
+		}
+
+		var input map[string]string
+		request.Decode(&input)
+		return stock.Respond(request, map[string]string{"shipment": input["reservation"] + "/" + input["charge"]})
+	})
+
+	checkout.RegisterWorkflow(&Workflow{
+		Name: "orders.place",
+		Nodes: []*WorkflowNode{
+			{Name: "reserve", Service: "stock", Action: "stock.reserve", Timeout: 2},
+			{Name: "charge", Service: "payments", Action: "payments.charge", Timeout: 2},
+			{
+				Name:       "ship",
+				Service:    "stock",
+				Action:     "stock.ship",
+				DependsOn:  []string{"reserve", "charge"},
+				Timeout:    2,
+				Retries:    1,
+				RetryDelay: 10 * time.Millisecond,
+				Input: func(run *WorkflowRun) (interface{}, error) {
+					var reserve, charge map[string]string
+					if err := run.Output("reserve", &reserve); err != nil {
+						return nil, err
+					}
+					if err := run.Output("charge", &charge); err != nil {
+						return nil, err
+					}
+					return map[string]string{"reservation": reserve["reservation"], "charge": charge["charge"]}, nil
+				},
+			},
+		},
+	})
+
+	run, err := checkout.RunWorkflow("orders.place", "dt-1", nil)
+	if err != nil {
+		t.Fatalf("RunWorkflow failed: %s", err.Error())
+	}
+
+	if run.Status != WorkflowSucceeded {
+		t.Fatalf("expected the run to succeed, got %s: %s", run.Status, run.Error)
+	}
+
+	var shipment map[string]string
+	if err := run.Output("ship", &shipment); err != nil || shipment["shipment"] != "r-1/c-1" {
+		t.Fatalf("expected ship to get the outputs of reserve and charge, got %v: %v", shipment, err)
+	}
+
+	if attempts := run.Nodes["ship"].Attempts; attempts != 2 {
+		t.Fatalf("expected ship to be retried once, got %d attempts", attempts)
+	}
+}
+
+func TestWorkflowNodeTimeout(t *testing.T) {
+	cluster := newFakeCluster()
+	checkout := cluster.service("checkout")
+	stock := cluster.service("stock")
+
+	// stock.count never responds its first call
+	var calls int32
+	stock.RegisterAction("stock.count", func(request *DataTransactionRequest) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil
+		}
+		return stock.Respond(request, 3)
+	})
+
+	node := &WorkflowNode{Name: "count", Service: "stock", Action: "stock.count", Timeout: 1, Retries: 1, RetryDelay: 10 * time.Millisecond}
+	checkout.RegisterWorkflow(&Workflow{Name: "stock.audit", Nodes: []*WorkflowNode{node}})
+
+	run, err := checkout.RunWorkflow("stock.audit", "dt-1", nil)
+	if err != nil {
+		t.Fatalf("RunWorkflow failed: %s", err.Error())
+	}
+
+	var timeout *TimeoutError
+	if run.Status != WorkflowFailed || !errors.As(run.failure, &timeout) || run.Nodes["count"].Attempts != 1 {
+		t.Fatalf("expected the node to time out without retry, got %s after %d attempts: %s", run.Status, run.Nodes["count"].Attempts, run.Error)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	node.RetryTimeouts = true
+
+	run, err = checkout.RunWorkflow("stock.audit", "dt-2", nil)
+	if err != nil {
+		t.Fatalf("RunWorkflow failed: %s", err.Error())
+	}
+
+	if run.Status != WorkflowSucceeded || run.Nodes["count"].Attempts != 2 {
+		t.Fatalf("expected the timed out node to be retried, got %s after %d attempts: %s", run.Status, run.Nodes["count"].Attempts, run.Error)
+	}
+}
+
+func TestWorkflowRequestRetry(t *testing.T) {
+	cluster := newFakeCluster()
+	orders := cluster.service("orders")
+	stock := cluster.service("stock")
+
+	var counts int32
+	stock.RegisterAction("stock.count", func(request *DataTransactionRequest) error {
+		return stock.Respond(request, atomic.AddInt32(&counts, 1))
+	})
+	stock.RegisterWorkflow(&Workflow{
+		Name:  "stock.audit",
+		Nodes: []*WorkflowNode{{Name: "count", Service: "stock", Action: "stock.count", Timeout: 2}},
+	})
+
+	audit := func(dtID string) *DataTransactionResponse {
+		receiver, err := orders.Exec(&ExecInput{DataTransactionID: dtID, Service: "stock", Action: "stock.audit", Timeout: 2})
+		if err != nil {
+			t.Fatalf("Exec failed: %s", err.Error())
+		}
+		return <-receiver
+	}
+
+	// Calling again the same data transaction answers the run already done
+	for i := 0; i < 2; i++ {
+		var output map[string]int
+		if response := audit("dt-1"); response == nil || response.Decode(&output) != nil || output["count"] != 1 {
+			t.Fatalf("expected the output of the first run, got %+v", response)
+		}
+	}
+
+	if calls := atomic.LoadInt32(&counts); calls != 1 {
+		t.Fatalf("expected the nodes to run once, got %d calls", calls)
+	}
+
+	// A run in progress is not started again
+	stock.workflows.store.Save(&WorkflowRun{DataTransactionID: "dt-2", Workflow: "stock.audit", Status: WorkflowRunning})
+
+	response := audit("dt-2")
+	var conflict *ConflictError
+	if response == nil || !errors.As(response.Err(), &conflict) || atomic.LoadInt32(&counts) != 1 {
+		t.Fatalf("expected the running workflow to be rejected, got %+v", response)
+	}
+}